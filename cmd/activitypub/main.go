@@ -124,6 +124,9 @@ func main() {
 		&activitypub.ApFollow{},
 		&activitypub.ApFollower{},
 		&activitypub.ApObjectReference{},
+		&activitypub.ApDelivery{},
+		&activitypub.ApForwarded{},
+		&activitypub.ApObjectSubscription{},
 	)
 
 	rdb := redis.NewClient(&redis.Options{
@@ -159,18 +162,26 @@ func main() {
 
 	e.GET("/.well-known/webfinger", activitypubHandler.WebFinger)
 	e.GET("/.well-known/nodeinfo", activitypubHandler.NodeInfoWellKnown)
+	e.GET("/.well-known/host-meta", activitypubHandler.HostMeta)
+	e.GET("/authorize_interaction", activitypubHandler.AuthorizeInteraction, auth.ParseJWT, authService.Restrict(auth.ISLOCAL)) // ISLOCAL
+	e.GET("/api/v1/instance", activitypubHandler.InstanceV1)
 
 	ap := e.Group("/ap")
 	ap.GET("/nodeinfo/2.0", activitypubHandler.NodeInfo)
+	ap.GET("/nodeinfo/2.1", activitypubHandler.NodeInfo21)
+	ap.GET("/actor", activitypubHandler.InstanceActor)
 	ap.GET("/acct/:id", activitypubHandler.User)
-	ap.POST("/acct/:id/inbox", activitypubHandler.Inbox)
+	ap.POST("/acct/:id/inbox", activitypubHandler.Inbox, activitypub.VerifyInboxSignature(activitypubHandler))
 	ap.POST("/acct/:id/outbox", activitypubHandler.PrintRequest)
+	ap.GET("/acct/:id/outbox", activitypubHandler.Outbox)
+	ap.GET("/acct/:id/followers", activitypubHandler.Followers)
+	ap.GET("/acct/:id/following", activitypubHandler.Following)
 	ap.GET("/note/:id", activitypubHandler.Note)
 
 	ap.GET("/api/entity/:ccid", activitypubHandler.GetEntityID)
 	ap.GET("/api/person/:id", activitypubHandler.GetPerson)
 
-	ap.POST("/inbox", activitypubHandler.Inbox)
+	ap.POST("/inbox", activitypubHandler.Inbox, activitypub.VerifyInboxSignature(activitypubHandler))
 
 	// should be restricted
 	apR := ap.Group("", auth.ParseJWT)
@@ -179,9 +190,14 @@ func main() {
 	apR.GET("/api/resolve/:id", activitypubHandler.ResolvePerson, authService.Restrict(auth.ISLOCAL)) // ISLOCAL
 	apR.POST("/api/follow/:id", activitypubHandler.Follow, authService.Restrict(auth.ISLOCAL))        // ISLOCAL
 	apR.DELETE("/api/follow/:id", activitypubHandler.UnFollow, authService.Restrict(auth.ISLOCAL))    // ISLOCAL
+	apR.POST("/api/move", activitypubHandler.Move, authService.Restrict(auth.ISLOCAL))                // ISLOCAL
 	apR.GET("/api/stats", activitypubHandler.GetStats, authService.Restrict(auth.ISLOCAL))            // ISLOCAL
 	apR.GET("/api/import", activitypubHandler.ImportNote, authService.Restrict(auth.ISLOCAL))         // ISLOCAL
 
+	apR.GET("/api/deliveries/pending", activitypubHandler.GetPendingDeliveries, authService.Restrict(auth.ISLOCAL)) // ISLOCAL
+	apR.GET("/api/deliveries/dead", activitypubHandler.GetDeadDeliveries, authService.Restrict(auth.ISLOCAL))    // ISLOCAL
+	apR.POST("/api/deliveries/:id/requeue", activitypubHandler.RequeueDelivery, authService.Restrict(auth.ISLOCAL)) // ISLOCAL
+
 	e.GET("/health", func(c echo.Context) (err error) {
 		ctx := c.Request().Context()
 
@@ -202,6 +218,7 @@ func main() {
 
 	go activitypubHandler.StartMessageWorker()
 	go activitypubHandler.StartAssociationWorker(apConf.Proxy.NotificationStream)
+	go activitypubHandler.StartDeliveryWorker()
 
 	e.Logger.Fatal(e.Start(":8000"))
 }
@@ -40,6 +40,7 @@ func SetupActivitypubHandler(db *gorm.DB, rdb *redis.Client, mc *memcache.Client
 	wire.Build(
 		activitypub.NewHandler,
 		activitypub.NewRepository,
+		activitypub.NewClient,
 		SetupMessageService,
 		SetupAssociationService,
 		SetupEntityService,
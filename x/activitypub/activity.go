@@ -0,0 +1,63 @@
+package activitypub
+
+import "encoding/json"
+
+// Item is implemented by every typed ActivityPub object or activity this
+// package knows how to decode. It mirrors go-ap/ForgeFed's item tree: a
+// single interface that lets callers dispatch on ActivityVocabularyType
+// without committing to one concrete struct up front.
+type Item interface {
+	GetType() string
+}
+
+func (o *Object) GetType() string { return o.Type }
+func (n *Note) GetType() string   { return n.Type }
+func (p *Person) GetType() string { return p.Type }
+func (c *Create) GetType() string { return c.Type }
+func (a *Accept) GetType() string { return a.Type }
+
+// itemTyper maps an ActivityVocabularyType to a constructor for its typed
+// struct, modeled on go-ap/ForgeFed's GetItemByType. Activity types that
+// don't yet have a dedicated struct (Announce, Like, Follow, Undo, Update,
+// Delete, Move, Article, Question, ...) fall back to the generic Object,
+// which already carries every field the handlers in this package read.
+var itemTyper = map[string]func() Item{
+	"Note":   func() Item { return &Note{} },
+	"Person": func() Item { return &Person{} },
+	"Create": func() Item { return &Create{} },
+	"Accept": func() Item { return &Accept{} },
+}
+
+// GetItemByType returns a zero-valued typed struct for the given
+// ActivityVocabularyType, or a generic *Object if the type has no
+// dedicated struct registered.
+func GetItemByType(t string) Item {
+	if ctor, ok := itemTyper[t]; ok {
+		return ctor()
+	}
+	return &Object{}
+}
+
+// typeProbe reads only the `type` field of a raw ActivityPub document, so
+// UnmarshalItem can pick the right typed struct before doing the real decode.
+type typeProbe struct {
+	Type string `json:"type"`
+}
+
+// UnmarshalItem does the two-pass decode this package needs to handle a
+// vocabulary as open-ended as ActivityPub's: it first reads just the `type`
+// field, looks up the matching struct via GetItemByType, then unmarshals
+// the full document into it.
+func UnmarshalItem(data []byte) (Item, error) {
+	var probe typeProbe
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	item := GetItemByType(probe.Type)
+	if err := json.Unmarshal(data, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
@@ -0,0 +1,103 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/buckket/go-blurhash"
+)
+
+// maxAttachmentFetchBytes bounds how much of a remote attachment we'll read
+// to derive its metadata, so a remote host can't exhaust memory with an
+// oversized or slow-drip response.
+const maxAttachmentFetchBytes = 8 << 20 // ~8MB
+
+// attachmentMeta holds the metadata we derive from an attachment image:
+// its real MIME type, pixel dimensions, and a blurhash placeholder so
+// clients can render a progressive preview instead of a broken image.
+type attachmentMeta struct {
+	MediaType string
+	Width     int
+	Height    int
+	Blurhash  string
+}
+
+// fetchAttachmentMeta downloads url once and derives its attachmentMeta,
+// caching the result in memcached so repeated references to the same image
+// (re-delivery, edits that keep the same attachment) don't re-fetch it.
+func (h Handler) fetchAttachmentMeta(ctx context.Context, url string) (attachmentMeta, error) {
+	ctx, span := tracer.Start(ctx, "FetchAttachmentMeta")
+	defer span.End()
+
+	cacheKey := "attachment:" + url
+	if cache, err := h.mc.Get(cacheKey); err == nil {
+		var meta attachmentMeta
+		if err := json.Unmarshal(cache.Value, &meta); err == nil {
+			return meta, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		span.RecordError(err)
+		return attachmentMeta{}, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return attachmentMeta{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentFetchBytes+1))
+	if err != nil {
+		span.RecordError(err)
+		return attachmentMeta{}, err
+	}
+	if len(body) > maxAttachmentFetchBytes {
+		err := errors.New("attachment exceeds max fetch size")
+		span.RecordError(err)
+		return attachmentMeta{}, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		span.RecordError(err)
+		return attachmentMeta{}, err
+	}
+
+	hash, err := blurhash.Encode(4, 3, img)
+	if err != nil {
+		span.RecordError(err)
+		return attachmentMeta{}, err
+	}
+
+	bounds := img.Bounds()
+	meta := attachmentMeta{
+		MediaType: http.DetectContentType(body),
+		Width:     bounds.Dx(),
+		Height:    bounds.Dy(),
+		Blurhash:  hash,
+	}
+
+	if metaBytes, err := json.Marshal(meta); err == nil {
+		h.mc.Set(&memcache.Item{
+			Key:        cacheKey,
+			Value:      metaBytes,
+			Expiration: 24 * 60 * 60, // 24 hours
+		})
+	}
+
+	return meta, nil
+}
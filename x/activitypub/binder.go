@@ -0,0 +1,77 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxNoteInboxBodyBytes bounds POSTs to a single actor's inbox.
+	maxNoteInboxBodyBytes = 1 << 20 // ~1MB
+	// maxSharedInboxBodyBytes bounds POSTs to the shared inbox, which fans
+	// in traffic for every local actor and so gets a higher ceiling.
+	maxSharedInboxBodyBytes = 10 << 20 // ~10MB
+)
+
+// Binder caps inbound ActivityPub inbox bodies and validates their Digest
+// header before decoding, so a single oversized or tampered POST can't
+// exhaust memory or reach a handler with a body that doesn't match what
+// was signed. Every other route falls through to echo's default binder.
+type Binder struct {
+	echo.DefaultBinder
+}
+
+// inboxBodyLimit reports the body size ceiling for path, and whether path
+// is an inbox route at all.
+func inboxBodyLimit(path string) (int64, bool) {
+	switch {
+	case path == "/ap/inbox":
+		return maxSharedInboxBodyBytes, true
+	case strings.HasSuffix(path, "/inbox"):
+		return maxNoteInboxBodyBytes, true
+	default:
+		return 0, false
+	}
+}
+
+// Bind implements echo.Binder.
+func (b *Binder) Bind(i interface{}, c echo.Context) error {
+	limit, isInbox := inboxBodyLimit(c.Request().URL.Path)
+	if !isInbox {
+		return b.DefaultBinder.Bind(i, c)
+	}
+
+	req := c.Request()
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read request body")
+	}
+	if int64(len(body)) > limit {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "request body too large")
+	}
+
+	digest := req.Header.Get("Digest")
+	if digest == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing Digest header")
+	}
+	if !strings.HasPrefix(digest, "SHA-256=") {
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported digest algorithm")
+	}
+	sum := sha256.Sum256(body)
+	expected := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+	if digest != expected {
+		return echo.NewHTTPError(http.StatusBadRequest, "digest mismatch")
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return json.NewDecoder(bytes.NewReader(body)).Decode(i)
+}
@@ -3,11 +3,16 @@ package activitypub
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +26,17 @@ var (
 	UserAgent = "ConcurrentWorker/1.0"
 )
 
+// DeliveryError wraps a failed inbox POST with the remote HTTP status code
+// so callers (namely the delivery worker) can decide whether to retry.
+type DeliveryError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Message
+}
+
 // FetchNote fetches a note from remote ap server.
 func (h Handler) FetchNote(ctx context.Context, noteID string, execEntity ApEntity) (Note, error) {
 	_, span := tracer.Start(ctx, "FetchNote")
@@ -36,7 +52,6 @@ func (h Handler) FetchNote(ctx context.Context, noteID string, execEntity ApEnti
 	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
 	req.Header.Set("User-Agent", UserAgent)
 	req.Header.Set("Host", req.URL.Host)
-	client := new(http.Client)
 
 	priv, err := h.repo.LoadKey(ctx, execEntity)
 	if err != nil {
@@ -44,7 +59,7 @@ func (h Handler) FetchNote(ctx context.Context, noteID string, execEntity ApEnti
 		return note, err
 	}
 
-	prefs := []httpsig.Algorithm{httpsig.RSA_SHA256}
+	prefs := []httpsig.Algorithm{httpsig.ED25519, httpsig.RSA_SHA512, httpsig.RSA_SHA256}
 	digestAlgorithm := httpsig.DigestSha256
 	headersToSign := []string{httpsig.RequestTarget, "date", "host"}
 	signer, _, err := httpsig.NewSigner(prefs, digestAlgorithm, headersToSign, httpsig.Signature, 0)
@@ -54,7 +69,7 @@ func (h Handler) FetchNote(ctx context.Context, noteID string, execEntity ApEnti
 	}
 	err = signer.SignRequest(priv, "https://"+h.config.Concurrent.FQDN+"/ap/acct/"+execEntity.ID+"#main-key", req, nil)
 
-	resp, err := client.Do(req)
+	resp, err := h.client.Do(req)
 	if err != nil {
 		return note, err
 	}
@@ -65,12 +80,16 @@ func (h Handler) FetchNote(ctx context.Context, noteID string, execEntity ApEnti
 		return note, err
 	}
 
-	err = json.Unmarshal(body, &note)
+	item, err := UnmarshalItem(body)
 	if err != nil {
 		return note, err
 	}
+	fetched, ok := item.(*Note)
+	if !ok {
+		return note, fmt.Errorf("unexpected object type: %s", item.GetType())
+	}
 
-	return note, nil
+	return *fetched, nil
 }
 
 // FetchPerson fetches a person from remote ap server.
@@ -98,7 +117,6 @@ func (h Handler) FetchPerson(ctx context.Context, actor string, execEntity ApEnt
 	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
 	req.Header.Set("User-Agent", UserAgent)
 	req.Header.Set("Host", req.URL.Host)
-	client := new(http.Client)
 
 	priv, err := h.repo.LoadKey(ctx, execEntity)
 	if err != nil {
@@ -106,7 +124,7 @@ func (h Handler) FetchPerson(ctx context.Context, actor string, execEntity ApEnt
 		return person, err
 	}
 
-	prefs := []httpsig.Algorithm{httpsig.RSA_SHA256}
+	prefs := []httpsig.Algorithm{httpsig.ED25519, httpsig.RSA_SHA512, httpsig.RSA_SHA256}
 	digestAlgorithm := httpsig.DigestSha256
 	headersToSign := []string{httpsig.RequestTarget, "date", "host"}
 	signer, _, err := httpsig.NewSigner(prefs, digestAlgorithm, headersToSign, httpsig.Signature, 0)
@@ -116,7 +134,7 @@ func (h Handler) FetchPerson(ctx context.Context, actor string, execEntity ApEnt
 	}
 	err = signer.SignRequest(priv, "https://"+h.config.Concurrent.FQDN+"/ap/acct/"+execEntity.ID+"#main-key", req, nil)
 
-	resp, err := client.Do(req)
+	resp, err := h.client.Do(req)
 	if err != nil {
 		return person, err
 	}
@@ -142,36 +160,127 @@ func (h Handler) FetchPerson(ctx context.Context, actor string, execEntity ApEnt
 	return person, nil
 }
 
-// ResolveActor resolves an actor from id notation.
-func ResolveActor(ctx context.Context, id string) (string, error) {
-	_, span := tracer.Start(ctx, "ResolveActor")
+const (
+	webfingerNegativeCacheValue = "\x00NXDOMAIN"
+	webfingerNegativeCacheTTL   = int32(5 * 60)         // 5 minutes
+	webfingerPositiveCacheTTL   = int32(24 * 60 * 60)   // 24 hours
+)
+
+// ResolveActor resolves an actor IRI from acct:user@host notation, caching
+// both positive and negative results in memcached.
+func (h Handler) ResolveActor(ctx context.Context, id string) (string, error) {
+	ctx, span := tracer.Start(ctx, "ResolveActor")
 	defer span.End()
 
 	if id[0] == '@' {
 		id = id[1:]
 	}
 
+	cacheKey := "webfinger:" + id
+	if cache, err := h.mc.Get(cacheKey); err == nil {
+		if string(cache.Value) == webfingerNegativeCacheValue {
+			return "", fmt.Errorf("no ap link found (cached negative result)")
+		}
+		return string(cache.Value), nil
+	}
+
 	split := strings.Split(id, "@")
 	if len(split) != 2 {
 		return "", fmt.Errorf("invalid id")
 	}
-
 	domain := split[1]
 
+	href, maxAge, err := fetchWebfinger(ctx, h.client, domain, id)
+	if err != nil || href == "" {
+		href, maxAge, err = fetchWebfingerViaHostMeta(ctx, h.client, domain, id)
+	}
+
+	if err != nil || href == "" {
+		h.mc.Set(&memcache.Item{
+			Key:        cacheKey,
+			Value:      []byte(webfingerNegativeCacheValue),
+			Expiration: webfingerNegativeCacheTTL,
+		})
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("no ap link found")
+	}
+
+	ttl := webfingerPositiveCacheTTL
+	if maxAge > 0 {
+		ttl = maxAge
+	}
+	h.mc.Set(&memcache.Item{
+		Key:        cacheKey,
+		Value:      []byte(href),
+		Expiration: ttl,
+	})
+
+	return href, nil
+}
+
+// fetchWebfinger queries a domain's WebFinger endpoint directly and selects
+// the self link that points at an ActivityPub actor. It returns the
+// Cache-Control max-age advertised by the response, if any.
+func fetchWebfinger(ctx context.Context, client *Client, domain, id string) (string, int32, error) {
 	targetlink := "https://" + domain + "/.well-known/webfinger?resource=acct:" + id
+	return requestWebfinger(ctx, client, targetlink)
+}
+
+// fetchWebfingerViaHostMeta follows /.well-known/host-meta's lrdd template to
+// locate WebFinger on another host, for servers that redirect vanity domains.
+func fetchWebfingerViaHostMeta(ctx context.Context, client *Client, domain, id string) (string, int32, error) {
+	req, err := http.NewRequest("GET", "https://"+domain+"/.well-known/host-meta", nil)
+	if err != nil {
+		return "", 0, err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	req.Header.Set("Accept", "application/xrd+xml")
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var hostMeta HostMeta
+	if err := xml.Unmarshal(body, &hostMeta); err != nil {
+		return "", 0, err
+	}
+
+	var template string
+	for _, link := range hostMeta.Links {
+		if link.Rel == "lrdd" {
+			template = link.Template
+			break
+		}
+	}
+	if template == "" {
+		return "", 0, fmt.Errorf("no lrdd template found")
+	}
 
+	targetlink := strings.Replace(template, "{uri}", url.QueryEscape("acct:"+id), 1)
+	return requestWebfinger(ctx, client, targetlink)
+}
+
+func requestWebfinger(ctx context.Context, client *Client, targetlink string) (string, int32, error) {
 	var webfinger WebFinger
 	req, err := http.NewRequest("GET", targetlink, nil)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 	req.Header.Set("Accept", "application/jrd+json")
 	req.Header.Set("User-Agent", UserAgent)
-	client := new(http.Client)
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer resp.Body.Close()
 
@@ -180,21 +289,70 @@ func ResolveActor(ctx context.Context, id string) (string, error) {
 	err = json.Unmarshal(body, &webfinger)
 	if err != nil {
 		fmt.Println(string(body))
-		return "", err
+		return "", 0, err
 	}
 
 	var aplink WebFingerLink
 	for _, link := range webfinger.Links {
-		if link.Rel == "self" {
+		if link.Rel == "self" && link.Type == "application/activity+json" {
 			aplink = link
+			break
 		}
 	}
 
 	if aplink.Href == "" {
-		return "", fmt.Errorf("no ap link found")
+		return "", 0, fmt.Errorf("no ap link found")
+	}
+
+	return aplink.Href, parseMaxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+// parseMaxAge extracts max-age from a Cache-Control header, returning 0 if absent or invalid.
+func parseMaxAge(cacheControl string) int32 {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err != nil {
+			return 0
+		}
+		return int32(seconds)
+	}
+	return 0
+}
+
+// VerifyRequestSignature verifies an inbound request's HTTP Signature against
+// the given actor's publicKeyPem, accepting any of the RSA/Ed25519
+// algorithms this package signs with.
+func VerifyRequestSignature(r *http.Request, publicKeyPem string) error {
+	block, _ := pem.Decode([]byte(publicKeyPem))
+	if block == nil {
+		return fmt.Errorf("failed to parse PEM block containing the public key")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: " + err.Error())
+	}
+
+	verifier, err := httpsig.NewVerifier(r)
+	if err != nil {
+		return fmt.Errorf("failed to construct verifier: " + err.Error())
 	}
 
-	return aplink.Href, nil
+	algorithms := []httpsig.Algorithm{httpsig.ED25519, httpsig.RSA_SHA512, httpsig.RSA_SHA256}
+	var lastErr error
+	for _, algo := range algorithms {
+		if err := verifier.Verify(pub, algo); err == nil {
+			return nil
+		} else {
+			lastErr = err
+		}
+	}
+
+	return fmt.Errorf("signature verification failed: %v", lastErr)
 }
 
 // PostToInbox posts a message to remote ap server.
@@ -214,7 +372,6 @@ func (h Handler) PostToInbox(ctx context.Context, inbox string, object interface
 	req.Header.Set("User-Agent", UserAgent)
 	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
 	req.Header.Set("Host", req.URL.Host)
-	client := new(http.Client)
 
 	priv, err := h.repo.LoadKey(ctx, entity)
 	if err != nil {
@@ -222,7 +379,7 @@ func (h Handler) PostToInbox(ctx context.Context, inbox string, object interface
 		return err
 	}
 
-	prefs := []httpsig.Algorithm{httpsig.RSA_SHA256}
+	prefs := []httpsig.Algorithm{httpsig.ED25519, httpsig.RSA_SHA512, httpsig.RSA_SHA256}
 	digestAlgorithm := httpsig.DigestSha256
 	headersToSign := []string{httpsig.RequestTarget, "date", "digest", "host"}
 	signer, _, err := httpsig.NewSigner(prefs, digestAlgorithm, headersToSign, httpsig.Signature, 0)
@@ -232,7 +389,7 @@ func (h Handler) PostToInbox(ctx context.Context, inbox string, object interface
 	}
 	err = signer.SignRequest(priv, "https://"+h.config.Concurrent.FQDN+"/ap/acct/"+entity.ID+"#main-key", req, objectBytes)
 
-	resp, err := client.Do(req)
+	resp, err := h.client.Do(req)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -245,10 +402,28 @@ func (h Handler) PostToInbox(ctx context.Context, inbox string, object interface
 	log.Printf("POST %s [%d]: %s", inbox, resp.StatusCode, string(body))
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
-		return fmt.Errorf("error posting to inbox: %d", resp.StatusCode)
+		return &DeliveryError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("error posting to inbox: %d", resp.StatusCode),
+		}
 	}
 
 	defer resp.Body.Close()
 
 	return nil
 }
+
+// SendAnnounce delivers an Announce (boost) activity for messageID to dest,
+// attributed to entity, announcing the AP object identified by ref.
+func (h Handler) SendAnnounce(ctx context.Context, dest string, messageID string, ref string, entity ApEntity) error {
+	announce := Object{
+		Context: []string{"https://www.w3.org/ns/activitystreams"},
+		Type:    "Announce",
+		ID:      "https://" + h.config.Concurrent.FQDN + "/ap/note/" + messageID,
+		Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + entity.ID,
+		Content: "",
+		Object:  ref,
+	}
+
+	return h.EnqueueDelivery(ctx, []string{dest}, announce, entity)
+}
@@ -12,6 +12,33 @@ import (
 	"time"
 )
 
+// messageBody is the typed shape of a Concurrent message SignedObject's
+// Body field. SignedObject.Body is an interface{} (it has to be, since the
+// schema varies), so rather than chase that with ad-hoc
+// `body["key"].(string)` assertions at every call site, decode it into
+// this struct once via decodeBody and let json tell us when a field is
+// missing or the wrong type instead of silently dropping it.
+type messageBody struct {
+	Body             string                      `json:"body"`
+	Emojis           map[string]messageBodyEmoji `json:"emojis"`
+	ReplyToMessageID string                      `json:"replyToMessageId"`
+	RerouteMessageID string                      `json:"rerouteMessageId"`
+}
+
+type messageBodyEmoji struct {
+	ImageURL string `json:"imageURL"`
+}
+
+// decodeBody re-marshals a SignedObject's loosely-typed Body/Meta field
+// into a concrete struct.
+func decodeBody(raw interface{}, out interface{}) error {
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
 func (h Handler) MessageToNote(ctx context.Context, messageID string) (Note, error) {
 	ctx, span := tracer.Start(ctx, "MessageToNote")
 	defer span.End()
@@ -34,57 +61,67 @@ func (h Handler) MessageToNote(ctx context.Context, messageID string) (Note, err
 		return Note{}, errors.New("invalid payload")
 	}
 
-	body, ok := signedObject.Body.(map[string]interface{})
-	if !ok {
+	var body messageBody
+	if err := decodeBody(signedObject.Body, &body); err != nil || body.Body == "" {
 		return Note{}, errors.New("invalid body")
 	}
 
 	var emojis []Tag
-	var images []string
 
-	text, ok := body["body"].(string)
-	if !ok {
-		return Note{}, errors.New("invalid body")
+	type imageRef struct {
+		Alt string
+		URL string
 	}
+	var images []imageRef
 
-	// extract image url of markdown notation
-	imagePattern := regexp.MustCompile(`!\[.*\]\((.*)\)`)
+	text := body.Body
+
+	// extract alt text and image url of markdown notation
+	imagePattern := regexp.MustCompile(`!\[(.*?)\]\((.*?)\)`)
 	matches := imagePattern.FindAllStringSubmatch(text, -1)
 	for _, match := range matches {
-		images = append(images, match[1])
+		images = append(images, imageRef{Alt: match[1], URL: match[2]})
 	}
 
 	// remove markdown notation
 	text = imagePattern.ReplaceAllString(text, "")
 
-	e, ok := body["emojis"].(map[string]interface{})
-	if ok {
-		for k, v := range e {
-			imageURL, ok := v.(map[string]interface{})["imageURL"].(string)
-			if !ok {
-				continue
-			}
-			emoji := Tag{
-				ID:   imageURL,
-				Type: "Emoji",
-				Name: ":" + k + ":",
-				Icon: Icon{
-					Type:      "Image",
-					MediaType: "image/png",
-					URL:       imageURL,
-				},
-			}
-			emojis = append(emojis, emoji)
+	for k, v := range body.Emojis {
+		if v.ImageURL == "" {
+			continue
+		}
+		emoji := Tag{
+			ID:   v.ImageURL,
+			Type: "Emoji",
+			Name: ":" + k + ":",
+			Icon: Icon{
+				Type:      "Image",
+				MediaType: "image/png",
+				URL:       v.ImageURL,
+			},
 		}
+		emojis = append(emojis, emoji)
 	}
 
 	attachments := []Attachment{}
-	for _, imageURL := range images {
+	for _, image := range images {
 		attachment := Attachment{
-			Type:      "Document",
-			MediaType: "image/png",
-			URL:       imageURL,
+			Type: "Document",
+			URL:  image.URL,
+			Name: image.Alt,
 		}
+
+		meta, err := h.fetchAttachmentMeta(ctx, image.URL)
+		if err != nil {
+			span.RecordError(err)
+			attachment.MediaType = "image/png"
+		} else {
+			attachment.MediaType = meta.MediaType
+			attachment.Width = meta.Width
+			attachment.Height = meta.Height
+			attachment.Blurhash = meta.Blurhash
+		}
+
 		attachments = append(attachments, attachment)
 	}
 
@@ -103,8 +140,8 @@ func (h Handler) MessageToNote(ctx context.Context, messageID string) (Note, err
 			Attachment:   attachments,
 		}, nil
 	} else if signedObject.Schema == "https://raw.githubusercontent.com/totegamma/concurrent-schemas/master/messages/reply/0.0.1.json" { // Reply
-		sourceID, ok := body["replyToMessageId"].(string)
-		if !ok {
+		sourceID := body.ReplyToMessageID
+		if sourceID == "" {
 			return Note{}, errors.New("invalid body")
 		}
 
@@ -140,8 +177,8 @@ func (h Handler) MessageToNote(ctx context.Context, messageID string) (Note, err
 			To:           []string{"https://www.w3.org/ns/activitystreams#Public"},
 		}, nil
 	} else if signedObject.Schema == "https://raw.githubusercontent.com/totegamma/concurrent-schemas/master/messages/reroute/0.0.1.json" { // Boost or Quote
-		sourceID, ok := body["rerouteMessageId"].(string)
-		if !ok {
+		sourceID := body.RerouteMessageID
+		if sourceID == "" {
 			return Note{}, errors.New("invalid body")
 		}
 
@@ -190,13 +227,21 @@ func (h Handler) MessageToNote(ctx context.Context, messageID string) (Note, err
 	}
 }
 
-func (h Handler) NoteToMessage(ctx context.Context, object Note, person Person, destStreams []string) (core.Message, error) {
+func (h Handler) NoteToMessage(ctx context.Context, object Note, person Person, destStreams []string, edited bool) (core.Message, error) {
 
 	content := object.Content
 
+	var attachments []WorldAttachment
 	for _, attachment := range object.Attachment {
 		if attachment.Type == "Document" {
-			content += "\n\n![image](" + attachment.URL + ")"
+			attachments = append(attachments, WorldAttachment{
+				URL:       attachment.URL,
+				MediaType: attachment.MediaType,
+				Width:     attachment.Width,
+				Height:    attachment.Height,
+				Blurhash:  attachment.Blurhash,
+				Name:      attachment.Name,
+			})
 		}
 	}
 
@@ -228,24 +273,48 @@ func (h Handler) NoteToMessage(ctx context.Context, object Note, person Person,
 		date = time.Now()
 	}
 
+	var editedAtString string
+	if edited {
+		editedAtString = object.Updated
+		if editedAtString == "" {
+			editedAtString = time.Now().UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	// if this Note replies to a note we hold a CC message for (via
+	// ApObjectReference), import it as a CC reply message (rather than a
+	// bare note) so it threads under that message; it is posted only to
+	// destStreams, which the caller chooses
+	schema := "https://raw.githubusercontent.com/totegamma/concurrent-schemas/master/messages/note/0.0.1.json"
+	body := map[string]interface{}{
+		"body": content,
+		"profileOverride": map[string]interface{}{
+			"username":    username,
+			"avatar":      person.Icon.URL,
+			"description": person.Summary,
+			"link":        person.URL,
+		},
+		"emojis":      emojis,
+		"attachments": attachments,
+	}
+
+	if object.InReplyTo != "" {
+		if parentRef, err := h.repo.GetApObjectReferenceByApObjectID(ctx, object.InReplyTo); err == nil && parentRef.CcObjectID != "" {
+			schema = "https://raw.githubusercontent.com/totegamma/concurrent-schemas/master/messages/reply/0.0.1.json"
+			body["replyToMessageId"] = parentRef.CcObjectID
+		}
+	}
+
 	b := message.SignedObject{
 		Signer: h.apconfig.ProxyCCID,
 		Type:   "Message",
-		Schema: "https://raw.githubusercontent.com/totegamma/concurrent-schemas/master/messages/note/0.0.1.json",
-		Body: map[string]interface{}{
-			"body": content,
-			"profileOverride": map[string]interface{}{
-				"username":    username,
-				"avatar":      person.Icon.URL,
-				"description": person.Summary,
-				"link":        person.URL,
-			},
-			"emojis": emojis,
-		},
+		Schema: schema,
+		Body:   body,
 		Meta: map[string]interface{}{
 			"apActor":          person.URL,
 			"apObjectRef":      object.ID,
 			"apPublisherInbox": person.Inbox,
+			"editedAt":         editedAtString,
 		},
 		SignedAt: date,
 	}
@@ -268,3 +337,50 @@ func (h Handler) NoteToMessage(ctx context.Context, object Note, person Person,
 
 	return created, nil
 }
+
+// DeleteToActivity builds the Delete activity announcing that a note this
+// bridge previously published has been removed, for delivery to whoever
+// received the original Create. The object is wrapped in a Tombstone, as
+// recommended by the ActivityPub spec for deleted objects.
+func (h Handler) DeleteToActivity(noteID string, actorID string) Object {
+	return Object{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Delete",
+		ID:      noteID + "/delete",
+		Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + actorID,
+		Object: Object{
+			Type: "Tombstone",
+			ID:   noteID,
+		},
+	}
+}
+
+// UndoToActivity builds the Undo activity retracting a previously-sent
+// Like, for when the underlying Concurrent association is removed.
+func (h Handler) UndoToActivity(likeID string, actorID string) Object {
+	return Object{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Undo",
+		ID:      likeID + "/undo",
+		Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + actorID,
+		Object: Object{
+			Type: "Like",
+			ID:   likeID,
+		},
+	}
+}
+
+// UndoAnnounceToActivity builds the Undo activity retracting a previously-sent
+// Announce (boost), for when the underlying Concurrent reroute is removed.
+func (h Handler) UndoAnnounceToActivity(announceID string, actorID string) Object {
+	return Object{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Undo",
+		ID:      announceID + "/undo",
+		Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + actorID,
+		Object: Object{
+			Type: "Announce",
+			ID:   announceID,
+		},
+	}
+}
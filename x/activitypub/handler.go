@@ -2,12 +2,11 @@
 package activitypub
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
+	"context"
 	"encoding/json"
-	"encoding/pem"
+	"errors"
 	"fmt"
+	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
 	"github.com/totegamma/concurrent/x/association"
@@ -21,6 +20,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -30,6 +30,8 @@ var tracer = otel.Tracer("activitypub")
 // Handler is a handler for the WebFinger protocol.
 type Handler struct {
 	repo        *Repository
+	client      *Client
+	mc          *memcache.Client
 	rdb         *redis.Client
 	message     message.Service
 	entity      entity.Service
@@ -42,6 +44,8 @@ type Handler struct {
 // NewHandler returns a new Handler.
 func NewHandler(
 	repo *Repository,
+	client *Client,
+	mc *memcache.Client,
 	rdb *redis.Client,
 	message message.Service,
 	entity entity.Service,
@@ -50,7 +54,7 @@ func NewHandler(
 	apconfig APConfig,
 	version string,
 ) *Handler {
-	return &Handler{repo, rdb, message, entity, association, config, apconfig, version}
+	return &Handler{repo, client, mc, rdb, message, entity, association, config, apconfig, version}
 }
 
 // :: Activitypub Related Functions ::
@@ -92,6 +96,10 @@ func (h Handler) WebFinger(c echo.Context) error {
 				Type: "application/activity+json",
 				Href: "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + username,
 			},
+			{
+				Rel:      "http://ostatus.org/schema/1.0/subscribe",
+				Template: "https://" + h.config.Concurrent.FQDN + "/authorize_interaction?uri={uri}",
+			},
 		},
 	})
 }
@@ -127,6 +135,16 @@ func (h Handler) User(c echo.Context) error {
 		return c.Redirect(http.StatusFound, "https://concurrent.world/entity/"+entity.CCID)
 	}
 
+	var alsoKnownAs []string
+	if entity.AlsoKnownAs != "" {
+		alsoKnownAs = strings.Split(entity.AlsoKnownAs, ",")
+	}
+
+	var movedTo string
+	if entity.MovedTo != "" {
+		movedTo = entity.MovedTo
+	}
+
 	c.Response().Header().Set("Content-Type", "application/activity+json")
 	return c.JSON(http.StatusOK, Person{
 		Context:     "https://www.w3.org/ns/activitystreams",
@@ -134,6 +152,8 @@ func (h Handler) User(c echo.Context) error {
 		ID:          "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id,
 		Inbox:       "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id + "/inbox",
 		Outbox:      "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id + "/outbox",
+		Followers:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id + "/followers",
+		Following:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id + "/following",
 		SharedInbox: "https://" + h.config.Concurrent.FQDN + "/ap/inbox",
 		Endpoints: PersonEndpoints{
 			SharedInbox: "https://" + h.config.Concurrent.FQDN + "/ap/inbox",
@@ -153,6 +173,49 @@ func (h Handler) User(c echo.Context) error {
 			Owner:        "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id,
 			PublicKeyPem: entity.Publickey,
 		},
+		AlsoKnownAs: alsoKnownAs,
+		MovedTo:     movedTo,
+	})
+}
+
+// InstanceActor handles requests for the bridge's own actor document, a
+// Service actor representing the instance itself rather than any single
+// Concurrent user. Other AP servers fetch this when they need an actor to
+// attribute server-to-server requests to (e.g. signed NodeInfo fetches)
+// that aren't on behalf of a specific local entity. It reuses the proxy
+// entity's keypair, the same identity the bridge already signs outbound
+// activities with when no more specific actor applies. It has no outbox of
+// its own, since it never publishes anything a remote server would page
+// through.
+func (h Handler) InstanceActor(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "InstanceActor")
+	defer span.End()
+
+	entity, err := h.repo.GetEntityByCCID(ctx, h.apconfig.ProxyCCID)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "instance actor not found")
+	}
+
+	c.Response().Header().Set("Content-Type", "application/activity+json")
+	return c.JSON(http.StatusOK, Person{
+		Context:           "https://www.w3.org/ns/activitystreams",
+		Type:              "Service",
+		ID:                "https://" + h.config.Concurrent.FQDN + "/ap/actor",
+		Inbox:             "https://" + h.config.Concurrent.FQDN + "/ap/inbox",
+		SharedInbox:       "https://" + h.config.Concurrent.FQDN + "/ap/inbox",
+		Endpoints: PersonEndpoints{
+			SharedInbox: "https://" + h.config.Concurrent.FQDN + "/ap/inbox",
+		},
+		PreferredUsername: h.config.Concurrent.FQDN,
+		Name:              h.config.Profile.Nickname,
+		URL:               "https://" + h.config.Concurrent.FQDN + "/ap/actor",
+		PublicKey: Key{
+			ID:           "https://" + h.config.Concurrent.FQDN + "/ap/actor#main-key",
+			Type:         "Key",
+			Owner:        "https://" + h.config.Concurrent.FQDN + "/ap/actor",
+			PublicKeyPem: entity.Publickey,
+		},
 	})
 }
 
@@ -191,6 +254,226 @@ func (h Handler) Note(c echo.Context) error {
 	return c.JSON(http.StatusOK, note)
 }
 
+// lastPage returns the 1-indexed number of the final page of a collection
+// of total items, paginated at pageSize per page.
+func lastPage(total int64, pageSize int) int64 {
+	if total <= 0 {
+		return 1
+	}
+	size := int64(pageSize)
+	pages := (total + size - 1) / size
+	if pages < 1 {
+		pages = 1
+	}
+	return pages
+}
+
+// Followers serves the followers OrderedCollection for a local entity. With
+// no ?page query param it just advertises totalItems and the first/last
+// page links; ?page=N returns that OrderedCollectionPage of follower actor
+// IRIs.
+func (h Handler) Followers(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Followers")
+	defer span.End()
+
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "Invalid username")
+	}
+
+	if _, err := h.repo.GetEntityByID(ctx, id); err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "entity not found")
+	}
+
+	total, err := h.repo.CountFollowersByUserID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	base := "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id + "/followers"
+
+	c.Response().Header().Set("Content-Type", "application/activity+json")
+
+	page := c.QueryParam("page")
+	if page == "" {
+		return c.JSON(http.StatusOK, OrderedCollection{
+			Context:    "https://www.w3.org/ns/activitystreams",
+			ID:         base,
+			Type:       "OrderedCollection",
+			TotalItems: total,
+			First:      base + "?page=1",
+			Last:       base + fmt.Sprintf("?page=%d", lastPage(total, h.apconfig.pageSize())),
+		})
+	}
+
+	pageNum, err := strconv.Atoi(page)
+	if err != nil || pageNum < 1 {
+		return c.String(http.StatusBadRequest, "Invalid page")
+	}
+
+	followers, err := h.repo.ListFollowersPaged(ctx, id, pageNum, h.apconfig.pageSize())
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	items := make([]string, 0, len(followers))
+	for _, follower := range followers {
+		items = append(items, follower.SubscriberPersonURL)
+	}
+
+	return c.JSON(http.StatusOK, newCollectionPage(base, page, pageNum, total, h.apconfig.pageSize(), items))
+}
+
+// Following serves the following OrderedCollection for a local entity,
+// paginated the same way as Followers.
+func (h Handler) Following(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Following")
+	defer span.End()
+
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "Invalid username")
+	}
+
+	if _, err := h.repo.GetEntityByID(ctx, id); err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "entity not found")
+	}
+
+	total, err := h.repo.CountFollowsByUserID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	base := "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id + "/following"
+
+	c.Response().Header().Set("Content-Type", "application/activity+json")
+
+	page := c.QueryParam("page")
+	if page == "" {
+		return c.JSON(http.StatusOK, OrderedCollection{
+			Context:    "https://www.w3.org/ns/activitystreams",
+			ID:         base,
+			Type:       "OrderedCollection",
+			TotalItems: total,
+			First:      base + "?page=1",
+			Last:       base + fmt.Sprintf("?page=%d", lastPage(total, h.apconfig.pageSize())),
+		})
+	}
+
+	pageNum, err := strconv.Atoi(page)
+	if err != nil || pageNum < 1 {
+		return c.String(http.StatusBadRequest, "Invalid page")
+	}
+
+	follows, err := h.repo.ListFollowsPaged(ctx, id, pageNum, h.apconfig.pageSize())
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	items := make([]string, 0, len(follows))
+	for _, follow := range follows {
+		items = append(items, follow.PublisherPersonURL)
+	}
+
+	return c.JSON(http.StatusOK, newCollectionPage(base, page, pageNum, total, h.apconfig.pageSize(), items))
+}
+
+// Outbox serves the local entity's outbox OrderedCollection. Each page
+// renders the entity's locally-authored messages, newest first, each
+// wrapped in a Create activity via MessageToNote.
+func (h Handler) Outbox(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Outbox")
+	defer span.End()
+
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "Invalid username")
+	}
+
+	if _, err := h.repo.GetEntityByID(ctx, id); err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "entity not found")
+	}
+
+	total, err := h.repo.CountApObjectReferencesByEntityID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	base := "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id + "/outbox"
+
+	c.Response().Header().Set("Content-Type", "application/activity+json")
+
+	page := c.QueryParam("page")
+	if page == "" {
+		return c.JSON(http.StatusOK, OrderedCollection{
+			Context:    "https://www.w3.org/ns/activitystreams",
+			ID:         base,
+			Type:       "OrderedCollection",
+			TotalItems: total,
+			First:      base + "?page=1",
+			Last:       base + fmt.Sprintf("?page=%d", lastPage(total, h.apconfig.pageSize())),
+		})
+	}
+
+	pageNum, err := strconv.Atoi(page)
+	if err != nil || pageNum < 1 {
+		return c.String(http.StatusBadRequest, "Invalid page")
+	}
+
+	refs, err := h.repo.ListApObjectReferencesByEntityIDPaged(ctx, id, pageNum, h.apconfig.pageSize())
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	items := make([]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		note, err := h.MessageToNote(ctx, ref.CcObjectID)
+		if err != nil {
+			span.RecordError(err)
+			continue
+		}
+		items = append(items, Create{
+			Context: "https://www.w3.org/ns/activitystreams",
+			ID:      ref.ApObjectID + "/activity",
+			Type:    "Create",
+			Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + id,
+			To:      []string{"https://www.w3.org/ns/activitystreams#Public"},
+			Object:  note,
+		})
+	}
+
+	return c.JSON(http.StatusOK, newCollectionPage(base, page, pageNum, total, h.apconfig.pageSize(), items))
+}
+
+// newCollectionPage builds an OrderedCollectionPage for pageNum (1-indexed)
+// of a collection rooted at base, linking to the adjacent pages implied by
+// total/pageSize.
+func newCollectionPage(base, page string, pageNum int, total int64, pageSize int, items interface{}) OrderedCollectionPage {
+	resp := OrderedCollectionPage{
+		Context:      "https://www.w3.org/ns/activitystreams",
+		ID:           base + "?page=" + page,
+		Type:         "OrderedCollectionPage",
+		PartOf:       base,
+		OrderedItems: items,
+	}
+	if int64(pageNum) < lastPage(total, pageSize) {
+		resp.Next = base + fmt.Sprintf("?page=%d", pageNum+1)
+	}
+	if pageNum > 1 {
+		resp.Prev = base + fmt.Sprintf("?page=%d", pageNum-1)
+	}
+	return resp
+}
+
 // Inbox handles inbox requests.
 func (h Handler) Inbox(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "HandlerAPInbox")
@@ -203,6 +486,11 @@ func (h Handler) Inbox(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "Invalid request body")
 	}
 
+	if actor, ok := verifiedActor(c); ok && actor != object.Actor {
+		log.Println("signed actor does not match object actor:", actor, object.Actor)
+		return c.String(http.StatusUnauthorized, "actor mismatch")
+	}
+
 	// testdump
 	objdump, err := json.Marshal(object)
 	if err != nil {
@@ -211,6 +499,10 @@ func (h Handler) Inbox(c echo.Context) error {
 	}
 	fmt.Println(string(objdump))
 
+	if object.Type == "Create" || object.Type == "Announce" || object.Type == "Undo" {
+		go h.forwardActivity(context.Background(), object, objdump)
+	}
+
 	switch object.Type {
 	case "Follow":
 
@@ -244,9 +536,9 @@ func (h Handler) Inbox(c echo.Context) error {
 		split := strings.Split(object.Object.(string), "/")
 		userID := split[len(split)-1]
 
-		err = h.PostToInbox(ctx, requester.Inbox, accept, entity)
+		err = h.EnqueueDelivery(ctx, []string{requester.Inbox}, accept, entity)
 		if err != nil {
-			log.Println("error posting to inbox", err)
+			log.Println("error enqueueing delivery", err)
 			span.RecordError(err)
 			return c.String(http.StatusInternalServerError, "Internal server error")
 		}
@@ -260,10 +552,11 @@ func (h Handler) Inbox(c echo.Context) error {
 
 		// save follow
 		err = h.repo.SaveFollower(ctx, ApFollower{
-			ID:                  object.ID,
-			SubscriberInbox:     requester.Inbox,
-			SubscriberPersonURL: requester.ID,
-			PublisherUserID:     userID,
+			ID:                    object.ID,
+			SubscriberInbox:       requester.Inbox,
+			SubscriberSharedInbox: requester.SharedInbox,
+			SubscriberPersonURL:   requester.ID,
+			PublisherUserID:       userID,
 		})
 		if err != nil {
 			log.Println("error saving follow", err)
@@ -274,7 +567,7 @@ func (h Handler) Inbox(c echo.Context) error {
 		c.Response().Header().Set("Content-Type", "application/activity+json")
 		return c.String(http.StatusOK, "follow accepted")
 
-	case "Like":
+	case "Like", "EmojiReact":
 		targetID := strings.Replace(object.Object.(string), "https://"+h.config.Concurrent.FQDN+"/ap/note/", "", 1)
 		targetMsg, err := h.message.Get(ctx, targetID)
 		if err != nil {
@@ -381,6 +674,136 @@ func (h Handler) Inbox(c echo.Context) error {
 
 		return c.String(http.StatusOK, "like accepted")
 
+	case "Announce":
+		targetIRI, ok := object.Object.(string)
+		if !ok || targetIRI == "" {
+			log.Println("Invalid announce object", object.Object)
+			return c.String(http.StatusBadRequest, "Invalid request body")
+		}
+
+		err = h.repo.CreateApObjectReference(ctx, ApObjectReference{
+			ApObjectID: object.ID,
+			CcObjectID: "",
+		})
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "announce already exists")
+		}
+
+		// list up follows of the announcer, to find a local entity we can fetch as
+		follows, err := h.repo.GetFollowsByPublisher(ctx, object.Actor)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusInternalServerError, "Internal server error (get follows error)")
+		}
+		var rep ApEntity
+		for _, follow := range follows {
+			entity, err := h.repo.GetEntityByID(ctx, follow.SubscriberUserID)
+			if err != nil {
+				span.RecordError(err)
+				continue
+			}
+			rep = entity
+		}
+		if rep.ID == "" {
+			log.Println("announce: no local follower of announcer", object.Actor)
+			return c.String(http.StatusOK, "No followers")
+		}
+
+		targetID := strings.Replace(targetIRI, "https://"+h.config.Concurrent.FQDN+"/ap/note/", "", 1)
+		if targetID == targetIRI {
+			// not one of our own notes; import the remote note if we haven't already
+			if ref, err := h.repo.GetApObjectReferenceByApObjectID(ctx, targetIRI); err == nil && ref.CcObjectID != "" {
+				targetID = ref.CcObjectID
+			} else {
+				note, err := h.FetchNote(ctx, targetIRI, rep)
+				if err != nil {
+					span.RecordError(err)
+					return c.String(http.StatusOK, "failed to fetch note")
+				}
+				notePerson, err := h.FetchPerson(ctx, note.AttributedTo, rep)
+				if err != nil {
+					span.RecordError(err)
+					return c.String(http.StatusOK, "failed to fetch actor")
+				}
+				imported, err := h.NoteToMessage(ctx, note, notePerson, []string{}, false)
+				if err != nil {
+					span.RecordError(err)
+					return c.String(http.StatusInternalServerError, "Internal server error (import note error)")
+				}
+				targetID = imported.ID
+				if err := h.repo.UpdateApObjectReference(ctx, ApObjectReference{ApObjectID: targetIRI, CcObjectID: targetID}); err != nil {
+					span.RecordError(err)
+				}
+			}
+		}
+
+		person, err := h.FetchPerson(ctx, object.Actor, rep)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "failed to fetch actor")
+		}
+
+		// if this announces one of our own notes, remember the announcer's
+		// inbox so future activities on that note get forwarded to them too
+		if strings.HasPrefix(targetIRI, "https://"+h.config.Concurrent.FQDN+"/ap/note/") {
+			if err := h.repo.AddObjectSubscription(ctx, targetIRI, person.Inbox); err != nil {
+				span.RecordError(err)
+			}
+		}
+
+		username := person.Name
+		if len(username) == 0 {
+			username = person.PreferredUsername
+		}
+
+		obj := association.SignedObject{
+			Signer: h.apconfig.ProxyCCID,
+			Type:   "Association",
+			Schema: "https://raw.githubusercontent.com/totegamma/concurrent-schemas/master/associations/reroute/0.0.1.json",
+			Body: map[string]interface{}{
+				"profileOverride": map[string]interface{}{
+					"username":    username,
+					"avatar":      person.Icon.URL,
+					"description": person.Summary,
+					"link":        object.Actor,
+				},
+			},
+			Meta: map[string]interface{}{
+				"apActor": object.Actor,
+			},
+			SignedAt: time.Now(),
+			Target:   targetID,
+		}
+
+		objb, err := json.Marshal(obj)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "Internal server error (json marshal error)")
+		}
+
+		objsig, err := util.SignBytes(objb, h.apconfig.Proxy.PrivateKey)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "Internal server error (sign error)")
+		}
+
+		created, err := h.association.PostAssociation(ctx, string(objb), objsig, []string{}, "messages")
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "Internal server error (post association error)")
+		}
+
+		err = h.repo.UpdateApObjectReference(ctx, ApObjectReference{
+			ApObjectID: object.ID,
+			CcObjectID: created.ID,
+		})
+		if err != nil {
+			span.RecordError(err)
+		}
+
+		return c.String(http.StatusOK, "announce accepted")
+
 	case "Create":
 		createObject, ok := object.Object.(map[string]interface{})
 		if !ok {
@@ -409,8 +832,9 @@ func (h Handler) Inbox(c echo.Context) error {
 
 			// preserve reference
 			err = h.repo.CreateApObjectReference(ctx, ApObjectReference{
-				ApObjectID: createID,
-				CcObjectID: "",
+				ApObjectID:   createID,
+				CcObjectID:   "",
+				AttributedTo: object.Actor,
 			})
 
 			if err != nil {
@@ -418,6 +842,21 @@ func (h Handler) Inbox(c echo.Context) error {
 				return c.String(http.StatusOK, "note already exists")
 			}
 
+			// convertObject
+			noteBytes, err := json.Marshal(createObject)
+			if err != nil {
+				log.Println("Internal server error (json marshal error)", err)
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (json marshal error)")
+			}
+			var note Note
+			err = json.Unmarshal(noteBytes, &note)
+			if err != nil {
+				log.Println("Internal server error (json unmarshal error)", err)
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (json unmarshal error)")
+			}
+
 			// list up follows
 			follows, err := h.repo.GetFollowsByPublisher(ctx, object.Actor)
 			if err != nil {
@@ -439,6 +878,22 @@ func (h Handler) Inbox(c echo.Context) error {
 				destStreams = append(destStreams, entity.FollowStream)
 			}
 
+			// a reply to one of our notes should reach the parent author's
+			// NotificationStream even if we have no local followers of the
+			// remote replier yet
+			if strings.HasPrefix(note.InReplyTo, "https://"+h.config.Concurrent.FQDN+"/ap/note/") {
+				if parentRef, err := h.repo.GetApObjectReferenceByApObjectID(ctx, note.InReplyTo); err == nil && parentRef.CcObjectID != "" {
+					if parentMsg, err := h.message.Get(ctx, parentRef.CcObjectID); err == nil {
+						if parentAuthor, err := h.repo.GetEntityByCCID(ctx, parentMsg.Author); err == nil {
+							destStreams = append(destStreams, parentAuthor.NotificationStream)
+							if rep.ID == "" {
+								rep = parentAuthor
+							}
+						}
+					}
+				}
+			}
+
 			if len(destStreams) == 0 {
 				log.Println("No followers")
 				return c.String(http.StatusOK, "No followers")
@@ -450,29 +905,23 @@ func (h Handler) Inbox(c echo.Context) error {
 				return c.String(http.StatusBadRequest, "failed to fetch actor")
 			}
 
-			// convertObject
-			noteBytes, err := json.Marshal(createObject)
-			if err != nil {
-				log.Println("Internal server error (json marshal error)", err)
-				span.RecordError(err)
-				return c.String(http.StatusInternalServerError, "Internal server error (json marshal error)")
-			}
-			var note Note
-			err = json.Unmarshal(noteBytes, &note)
-			if err != nil {
-				log.Println("Internal server error (json unmarshal error)", err)
-				span.RecordError(err)
-				return c.String(http.StatusInternalServerError, "Internal server error (json unmarshal error)")
-			}
-
-			created, err := h.NoteToMessage(ctx, note, person, destStreams)
+			created, err := h.NoteToMessage(ctx, note, person, destStreams, false)
 
 			// save reference
 			err = h.repo.UpdateApObjectReference(ctx, ApObjectReference{
-				ApObjectID: createID,
-				CcObjectID: created.ID,
+				ApObjectID:   createID,
+				CcObjectID:   created.ID,
+				AttributedTo: object.Actor,
 			})
 
+			// if this is a reply to one of our notes, remember the replier's
+			// inbox so future activities on that note get forwarded to them too
+			if strings.HasPrefix(note.InReplyTo, "https://"+h.config.Concurrent.FQDN+"/ap/note/") {
+				if err := h.repo.AddObjectSubscription(ctx, note.InReplyTo, person.Inbox); err != nil {
+					span.RecordError(err)
+				}
+			}
+
 			return c.String(http.StatusOK, "note accepted")
 		default:
 			// print request body
@@ -595,6 +1044,31 @@ func (h Handler) Inbox(c echo.Context) error {
 			}
 			return c.String(http.StatusOK, "like undoed")
 
+		case "Announce":
+			announceID, ok := undoObject["id"].(string)
+			if !ok {
+				log.Println("Invalid undo object", object.Object)
+				return c.String(http.StatusOK, "Invalid request body")
+			}
+			deleteRef, err := h.repo.GetApObjectReferenceByApObjectID(ctx, announceID)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusNotFound, "announce not found")
+			}
+
+			_, err = h.association.Delete(ctx, deleteRef.CcObjectID)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (delete announce error)")
+			}
+
+			err = h.repo.DeleteApObjectReference(ctx, deleteRef.ApObjectID)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (delete reference error)")
+			}
+			return c.String(http.StatusOK, "announce undoed")
+
 		default:
 			// print request body
 			b, err := json.Marshal(object)
@@ -606,48 +1080,379 @@ func (h Handler) Inbox(c echo.Context) error {
 			return c.String(http.StatusOK, "OK but not implemented")
 		}
 	case "Delete":
-		deleteObject, ok := object.Object.(map[string]interface{})
-		if !ok {
+		switch deleteObject := object.Object.(type) {
+		case map[string]interface{}:
+			deleteID, ok := deleteObject["id"].(string)
+			if !ok {
+				log.Println("Invalid delete object", object.Object)
+				return c.String(http.StatusOK, "Invalid request body")
+			}
+
+			deleteRef, err := h.repo.GetApObjectReferenceByApObjectID(ctx, deleteID)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusOK, "Object Already Deleted")
+			}
+
+			_, err = h.message.Delete(ctx, deleteRef.CcObjectID)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (delete error)")
+			}
+
+			err = h.repo.DeleteApObjectReference(ctx, deleteRef.ApObjectID)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (delete error)")
+			}
+			return c.String(http.StatusOK, "Deleted")
+
+		case string:
+			// Mastodon-family servers send Actor deletion as {"object": "<actorIRI>"}.
+			// We keep no mirror of remote actors beyond our follow graph, so
+			// tombstoning one just means dropping every ApFollow/ApFollower
+			// row that references it.
+			actorURL := deleteObject
+			if actorURL == "" {
+				return c.String(http.StatusOK, "Invalid request body")
+			}
+
+			follows, err := h.repo.GetFollowsByPublisher(ctx, actorURL)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (get follows error)")
+			}
+			for _, follow := range follows {
+				if _, err := h.repo.RemoveFollow(ctx, follow.ID); err != nil {
+					log.Println("actor delete: remove follow failed", err)
+					span.RecordError(err)
+				}
+			}
+
+			followers, err := h.repo.GetFollowersBySubscriber(ctx, actorURL)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (get followers error)")
+			}
+			for _, follower := range followers {
+				if err := h.repo.RemoveFollowerByID(ctx, follower.ID); err != nil {
+					log.Println("actor delete: remove follower failed", err)
+					span.RecordError(err)
+				}
+			}
+
+			return c.String(http.StatusOK, "actor tombstoned")
+
+		default:
 			log.Println("Invalid delete object", object.Object)
 			return c.String(http.StatusOK, "Invalid request body")
 		}
-		deleteID, ok := deleteObject["id"].(string)
-		if !ok {
-			log.Println("Invalid delete object", object.Object)
+
+	case "Update":
+		updateBytes, err := json.Marshal(object.Object)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusInternalServerError, "Internal server error (json marshal error)")
+		}
+		item, err := UnmarshalItem(updateBytes)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "Invalid request body")
+		}
+		if person, ok := item.(*Person); ok && person.ID != "" {
+			// the actor document has changed (e.g. display name, avatar, key
+			// rotation); drop our cached copy and re-fetch so FetchPerson
+			// picks up the new fields (including the public key used to
+			// verify this actor's future signed requests) on next use.
+			h.mc.Delete(person.ID)
+
+			entity, err := h.repo.GetEntityByCCID(ctx, h.apconfig.ProxyCCID)
+			if err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusInternalServerError, "Internal server error (proxy entity not found)")
+			}
+
+			if _, err := h.FetchPerson(ctx, person.ID, entity); err != nil {
+				span.RecordError(err)
+				return c.String(http.StatusOK, "failed to refresh actor")
+			}
+
+			return c.String(http.StatusOK, "actor updated")
+		}
+
+		note, ok := item.(*Note)
+		if !ok || note.ID == "" {
+			log.Println("Unhandled Update Object", item.GetType())
+			return c.String(http.StatusOK, "OK but not implemented")
+		}
+
+		ref, err := h.repo.GetApObjectReferenceByApObjectID(ctx, note.ID)
+		if err != nil || ref.CcObjectID == "" {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "mirrored object not found")
+		}
+
+		// only the actor who originally authored this mirrored note may
+		// replay it in an Update; otherwise any signed-in actor could
+		// overwrite someone else's post by naming its known note ID.
+		if ref.AttributedTo != "" && ref.AttributedTo != object.Actor {
+			log.Println("update: actor does not match mirrored note's original author", object.Actor, ref.AttributedTo)
+			return c.String(http.StatusUnauthorized, "actor mismatch")
+		}
+
+		msg, err := h.message.Get(ctx, ref.CcObjectID)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "mirrored object not found")
+		}
+
+		entity, err := h.repo.GetEntityByCCID(ctx, msg.Author)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "entity not found")
+		}
+
+		person, err := h.FetchPerson(ctx, object.Actor, entity)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusOK, "failed to fetch actor")
+		}
+
+		_, err = h.message.Delete(ctx, ref.CcObjectID)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusInternalServerError, "Internal server error (delete error)")
+		}
+
+		updated, err := h.NoteToMessage(ctx, *note, person, []string{}, true)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusInternalServerError, "Internal server error (update note error)")
+		}
+
+		err = h.repo.UpdateApObjectReference(ctx, ApObjectReference{
+			ApObjectID:   note.ID,
+			CcObjectID:   updated.ID,
+			AttributedTo: ref.AttributedTo,
+		})
+		if err != nil {
+			span.RecordError(err)
+		}
+
+		return c.String(http.StatusOK, "note updated")
+
+	case "Move":
+		// ActivityPub Move: a followed actor has migrated to object.Target.
+		// We rewrite our ApFollow rows to the new actor, re-follow it so
+		// delivery keeps flowing, and Undo the stale Follow on the old actor.
+		oldActor, ok := object.Object.(string)
+		if !ok || oldActor == "" || object.Target == "" {
+			log.Println("Invalid move object", object.Object)
 			return c.String(http.StatusOK, "Invalid request body")
 		}
 
-		deleteRef, err := h.repo.GetApObjectReferenceByApObjectID(ctx, deleteID)
-		if err != nil {
-			span.RecordError(err)
-			return c.String(http.StatusOK, "Object Already Deleted")
-		}
+		// A Move must be signed by the actor being moved, not an arbitrary
+		// third party: otherwise anyone could name someone else's follows
+		// as the Move's object and hijack them onto an actor they control.
+		if object.Actor != oldActor {
+			log.Println("move: actor does not match object being moved", object.Actor, oldActor)
+			return c.String(http.StatusUnauthorized, "actor mismatch")
+		}
+
+		follows, err := h.repo.GetFollowsByPublisher(ctx, oldActor)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusInternalServerError, "Internal server error (get follows error)")
+		}
+
+		for _, follow := range follows {
+			subscriber, err := h.repo.GetEntityByID(ctx, follow.SubscriberUserID)
+			if err != nil {
+				log.Println("move: subscriber entity not found", err)
+				span.RecordError(err)
+				continue
+			}
+
+			newPerson, err := h.FetchPerson(ctx, object.Target, subscriber)
+			if err != nil {
+				log.Println("move: fetch new actor failed", err)
+				span.RecordError(err)
+				continue
+			}
+
+			// The new actor must claim the old one via alsoKnownAs, so a
+			// Move can only redirect follows to an actor that has actually
+			// declared itself a continuation of oldActor.
+			if !isInList(oldActor, newPerson.AlsoKnownAs) {
+				log.Println("move: new actor does not claim old actor in alsoKnownAs", newPerson.ID, oldActor)
+				continue
+			}
+
+			actorID := "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + subscriber.ID
+
+			err = h.EnqueueDelivery(ctx, []string{newPerson.Inbox}, Object{
+				Context: "https://www.w3.org/ns/activitystreams",
+				Type:    "Follow",
+				Actor:   actorID,
+				Object:  newPerson.ID,
+				ID:      follow.ID,
+			}, subscriber)
+			if err != nil {
+				log.Println("move: follow new actor failed", err)
+				span.RecordError(err)
+			}
+
+			if oldPerson, err := h.FetchPerson(ctx, oldActor, subscriber); err == nil {
+				err = h.EnqueueDelivery(ctx, []string{oldPerson.Inbox}, Object{
+					Context: "https://www.w3.org/ns/activitystreams",
+					Type:    "Undo",
+					Actor:   actorID,
+					ID:      follow.ID + "/undo",
+					Object: Object{
+						Context: "https://www.w3.org/ns/activitystreams",
+						Type:    "Follow",
+						ID:      follow.ID,
+						Actor:   actorID,
+						Object:  oldActor,
+					},
+				}, subscriber)
+				if err != nil {
+					log.Println("move: undo old follow failed", err)
+					span.RecordError(err)
+				}
+			}
+
+			follow.PublisherPersonURL = newPerson.ID
+			if _, err := h.repo.UpdateFollow(ctx, follow); err != nil {
+				log.Println("move: update follow failed", err)
+				span.RecordError(err)
+			}
+		}
+
+		return c.String(http.StatusOK, "follows migrated")
+
+	default:
+		// print request body
+		b, err := json.Marshal(object)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusInternalServerError, "Internal server error (json marshal error)")
+		}
+		log.Println("Unhandled Activitypub Object", string(b))
+		return c.String(http.StatusOK, "OK but not implemented")
+	}
+
+	// return c.String(http.StatusInternalServerError, "Internal server error")
+}
+
+// forwardActivity implements ActivityPub §7.1.2 inbox forwarding: when a
+// Create/Announce/Undo{Follow|Announce} references an object we own (via
+// ApObjectReference), the original activity JSON is re-posted to every
+// follower of that object's author, plus anyone subscribed to the object,
+// that isn't already in the activity's own to/cc audience. Each activity
+// URI is forwarded at most once.
+func (h Handler) forwardActivity(ctx context.Context, object Object, rawActivity []byte) {
+	ctx, span := tracer.Start(ctx, "ForwardActivity")
+	defer span.End()
+
+	if object.ID == "" {
+		return
+	}
+	if _, err := h.repo.GetForwarded(ctx, object.ID); err == nil {
+		return
+	}
+
+	var targetID string
+	switch object.Type {
+	case "Announce":
+		targetID, _ = object.Object.(string)
+	case "Create":
+		if inner, ok := object.Object.(map[string]interface{}); ok {
+			targetID, _ = inner["inReplyTo"].(string)
+		}
+	case "Undo":
+		if inner, ok := object.Object.(map[string]interface{}); ok {
+			innerType, _ := inner["type"].(string)
+			if innerType == "Follow" || innerType == "Announce" {
+				targetID, _ = inner["object"].(string)
+			}
+		}
+	}
+
+	if targetID == "" || !strings.HasPrefix(targetID, "https://"+h.config.Concurrent.FQDN+"/ap/note/") {
+		return
+	}
+
+	ref, err := h.repo.GetApObjectReferenceByApObjectID(ctx, targetID)
+	if err != nil || ref.CcObjectID == "" {
+		return
+	}
+
+	msg, err := h.message.Get(ctx, ref.CcObjectID)
+	if err != nil {
+		log.Println("forwardActivity: message not found", err)
+		return
+	}
+
+	entity, err := h.repo.GetEntityByCCID(ctx, msg.Author)
+	if err != nil {
+		log.Println("forwardActivity: entity not found", err)
+		return
+	}
+
+	followers, err := h.repo.GetFollowers(ctx, entity.ID)
+	if err != nil {
+		log.Println("forwardActivity: get followers failed", err)
+		return
+	}
+
+	subscribers, err := h.repo.ListObjectSubscribers(ctx, targetID)
+	if err != nil {
+		log.Println("forwardActivity: list subscribers failed", err)
+	}
+
+	audience := make(map[string]bool)
+	for _, a := range object.To {
+		audience[a] = true
+	}
+	for _, a := range object.Cc {
+		audience[a] = true
+	}
 
-		_, err = h.message.Delete(ctx, deleteRef.CcObjectID)
-		if err != nil {
-			span.RecordError(err)
-			return c.String(http.StatusInternalServerError, "Internal server error (delete error)")
+	dests := make(map[string]bool)
+	for _, f := range followers {
+		if f.SubscriberInbox != "" && !audience[f.SubscriberPersonURL] {
+			dests[preferredInbox(f)] = true
 		}
+	}
+	for _, s := range subscribers {
+		dests[s.SubscriberInbox] = true
+	}
 
-		err = h.repo.DeleteApObjectReference(ctx, deleteRef.ApObjectID)
-		if err != nil {
-			span.RecordError(err)
-			return c.String(http.StatusInternalServerError, "Internal server error (delete error)")
-		}
-		return c.String(http.StatusOK, "Deleted")
+	// never bounce the activity back to the actor that sent it
+	if sender, err := h.FetchPerson(ctx, object.Actor, entity); err == nil {
+		delete(dests, sender.Inbox)
+	}
 
-	default:
-		// print request body
-		b, err := json.Marshal(object)
-		if err != nil {
-			span.RecordError(err)
-			return c.String(http.StatusInternalServerError, "Internal server error (json marshal error)")
-		}
-		log.Println("Unhandled Activitypub Object", string(b))
-		return c.String(http.StatusOK, "OK but not implemented")
+	if len(dests) == 0 {
+		return
 	}
 
-	// return c.String(http.StatusInternalServerError, "Internal server error")
+	inboxes := make([]string, 0, len(dests))
+	for inbox := range dests {
+		inboxes = append(inboxes, inbox)
+	}
+
+	err = h.EnqueueDelivery(ctx, inboxes, json.RawMessage(rawActivity), entity)
+	if err != nil {
+		log.Println("forwardActivity: enqueue delivery failed", err)
+		return
+	}
+
+	err = h.repo.CreateForwarded(ctx, ApForwarded{ActivityID: object.ID})
+	if err != nil {
+		log.Println("forwardActivity: record forwarded failed", err)
+	}
 }
 
 // :: Database related functions ::
@@ -672,6 +1477,47 @@ func (h Handler) GetPerson(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": person})
 }
 
+// ResolvePerson resolves a remote account identifier ("@user@domain" or a
+// bare actor IRI) to its ActivityPub Person document, signing the fetch as
+// the authenticated viewer's own entity. It backs GET /ap/api/resolve/:id.
+func (h Handler) ResolvePerson(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ResolvePerson")
+	defer span.End()
+
+	claims := c.Get("jwtclaims").(jwt.Claims)
+	ccid := claims.Issuer
+	entity, err := h.repo.GetEntityByCCID(ctx, ccid)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "entity not found")
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "Invalid id")
+	}
+
+	actor := id
+	if id[0] != '@' && !strings.HasPrefix(id, "https://") {
+		id = "@" + id
+	}
+	if id[0] == '@' {
+		actor, err = h.ResolveActor(ctx, id)
+		if err != nil {
+			span.RecordError(err)
+			return c.String(http.StatusNotFound, "actor not found")
+		}
+	}
+
+	person, err := h.FetchPerson(ctx, actor, entity)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "actor not found")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": person})
+}
+
 // UpdatePerson handles entity updates.
 func (h Handler) UpdatePerson(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "UpdatePerson")
@@ -706,41 +1552,34 @@ func (h Handler) UpdatePerson(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": created})
 }
 
-// Follow handles entity follow requests.
-func (h Handler) Follow(c echo.Context) error {
-	ctx, span := tracer.Start(c.Request().Context(), "Follow")
-	defer span.End()
-
-	claims := c.Get("jwtclaims").(jwt.Claims)
-	ccid := claims.Issuer
-	entity, err := h.repo.GetEntityByCCID(ctx, ccid)
-	if err != nil {
-		span.RecordError(err)
-		return c.String(http.StatusNotFound, "entity not found")
-	}
-
-	targetID := c.Param("id")
+// followActor resolves targetID (an "@user@domain" handle or a bare actor
+// IRI) and issues a Follow from entity, saving the resulting ApFollow row.
+// It backs both the Follow handler and the remote-follow entry point in
+// handlers_ostatus.go, which needs the exact same flow starting from a
+// viewer-supplied URI instead of a path parameter.
+func (h Handler) followActor(ctx context.Context, entity ApEntity, targetID string) (ApFollow, error) {
 	if targetID == "" {
-		return c.String(http.StatusBadRequest, "Invalid username")
+		return ApFollow{}, errors.New("invalid target")
 	}
-
-	if targetID[0] != '@' {
+	if targetID[0] != '@' && !strings.HasPrefix(targetID, "https://") {
 		targetID = "@" + targetID
 	}
 
 	log.Println("follow", targetID)
 
-	targetActor, err := ResolveActor(ctx, targetID)
-	if err != nil {
-		log.Println("resolve actor error", err)
-		span.RecordError(err)
-		return c.String(http.StatusNotFound, "entity not found")
+	targetActor := targetID
+	if targetID[0] == '@' {
+		var err error
+		targetActor, err = h.ResolveActor(ctx, targetID)
+		if err != nil {
+			log.Println("resolve actor error", err)
+			return ApFollow{}, err
+		}
 	}
 
 	targetPerson, err := h.FetchPerson(ctx, targetActor, entity)
 	if err != nil {
-		span.RecordError(err)
-		return c.String(http.StatusNotFound, "entity not found")
+		return ApFollow{}, err
 	}
 
 	simpleID := strings.Replace(targetID, "@", "-", -1)
@@ -755,11 +1594,10 @@ func (h Handler) Follow(c echo.Context) error {
 		ID:      followID,
 	}
 
-	err = h.PostToInbox(ctx, targetPerson.Inbox, followObject, entity)
+	err = h.EnqueueDelivery(ctx, []string{targetPerson.Inbox}, followObject, entity)
 	if err != nil {
-		log.Println("post to inbox error", err)
-		span.RecordError(err)
-		return c.String(http.StatusInternalServerError, "Internal server error")
+		log.Println("enqueue delivery error", err)
+		return ApFollow{}, err
 	}
 
 	follow := ApFollow{
@@ -771,6 +1609,32 @@ func (h Handler) Follow(c echo.Context) error {
 	err = h.repo.SaveFollow(ctx, follow)
 	if err != nil {
 		log.Println("save follow error", err)
+		return ApFollow{}, err
+	}
+
+	return follow, nil
+}
+
+// Follow handles entity follow requests.
+func (h Handler) Follow(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Follow")
+	defer span.End()
+
+	claims := c.Get("jwtclaims").(jwt.Claims)
+	ccid := claims.Issuer
+	entity, err := h.repo.GetEntityByCCID(ctx, ccid)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "entity not found")
+	}
+
+	targetID := c.Param("id")
+	if targetID == "" {
+		return c.String(http.StatusBadRequest, "Invalid username")
+	}
+
+	follow, err := h.followActor(ctx, entity, targetID)
+	if err != nil {
 		span.RecordError(err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
 	}
@@ -806,7 +1670,7 @@ func (h Handler) UnFollow(c echo.Context) error {
 	followID := "https://" + h.config.Concurrent.FQDN + "/follow/" + entity.ID + "/" + simpleID
 	log.Println("unfollow", followID)
 
-	targetActor, err := ResolveActor(ctx, targetID)
+	targetActor, err := h.ResolveActor(ctx, targetID)
 	if err != nil {
 		span.RecordError(err)
 		return c.String(http.StatusNotFound, "entity not found")
@@ -840,7 +1704,7 @@ func (h Handler) UnFollow(c echo.Context) error {
 	}
 	log.Println(string(undoJSON))
 
-	err = h.PostToInbox(ctx, targetPerson.Inbox, undoObject, entity)
+	err = h.EnqueueDelivery(ctx, []string{targetPerson.Inbox}, undoObject, entity)
 	if err != nil {
 		span.RecordError(err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
@@ -855,6 +1719,66 @@ func (h Handler) UnFollow(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": deleted})
 }
 
+// Move migrates the authenticated entity to another actor. It records
+// MovedTo on the entity and sends a Move activity to every known follower
+// so well-behaved remote servers re-point their Follow at the new actor.
+func (h Handler) Move(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "Move")
+	defer span.End()
+
+	claims := c.Get("jwtclaims").(jwt.Claims)
+	ccid := claims.Issuer
+
+	entity, err := h.repo.GetEntityByCCID(ctx, ccid)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "entity not found")
+	}
+
+	var request MoveRequest
+	err = c.Bind(&request)
+	if err != nil || request.Target == "" {
+		span.RecordError(err)
+		return c.String(http.StatusBadRequest, "Invalid request body")
+	}
+
+	entity.MovedTo = request.Target
+	entity, err = h.repo.UpdateEntity(ctx, entity)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	followers, err := h.repo.GetFollowers(ctx, entity.ID)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error (get followers error)")
+	}
+
+	actorID := "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + entity.ID
+	moveObject := Object{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Move",
+		ID:      actorID + "/move/" + url.PathEscape(request.Target),
+		Actor:   actorID,
+		Object:  actorID,
+		Target:  request.Target,
+	}
+
+	inboxes := make([]string, 0, len(followers))
+	for _, follower := range followers {
+		inboxes = append(inboxes, preferredInbox(follower))
+	}
+	if err := h.EnqueueDelivery(ctx, inboxes, moveObject, entity); err != nil {
+		log.Println("move: enqueue follower notifications failed", err)
+		span.RecordError(err)
+	}
+
+	entity.Privatekey = ""
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": entity})
+}
+
 // CreateEntity handles entity creation.
 func (h Handler) CreateEntity(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "CreateEntity")
@@ -876,6 +1800,7 @@ func (h Handler) CreateEntity(c echo.Context) error {
 		entity.HomeStream = request.HomeStream
 		entity.NotificationStream = request.NotificationStream
 		entity.FollowStream = request.FollowStream
+		entity.AlsoKnownAs = request.AlsoKnownAs
 
 		updated, err := h.repo.UpdateEntity(ctx, entity)
 		if err != nil {
@@ -888,41 +1813,27 @@ func (h Handler) CreateEntity(c echo.Context) error {
 		return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": updated})
 	} else { // Create
 
-		// RSAキーペアの生成
-		privKey, err := rsa.GenerateKey(rand.Reader, 2048)
-		if err != nil {
-			panic(err)
+		keyType := request.KeyType
+		if keyType == "" {
+			keyType = "rsa"
 		}
 
-		// 秘密鍵をPEM形式に変換
-		privKeyBytes := x509.MarshalPKCS1PrivateKey(privKey)
-		privKeyPEM := pem.EncodeToMemory(
-			&pem.Block{
-				Type:  "RSA PRIVATE KEY",
-				Bytes: privKeyBytes,
-			},
-		)
-
-		// 公開鍵をPEM形式に変換
-		pubKeyBytes, err := x509.MarshalPKIXPublicKey(&privKey.PublicKey)
+		pubKeyPEM, privKeyPEM, err := GenerateKeyPair(keyType)
 		if err != nil {
-			panic(err)
+			span.RecordError(err)
+			return c.String(http.StatusInternalServerError, "Internal server error")
 		}
-		pubKeyPEM := pem.EncodeToMemory(
-			&pem.Block{
-				Type:  "PUBLIC KEY",
-				Bytes: pubKeyBytes,
-			},
-		)
 
 		created, err := h.repo.CreateEntity(ctx, ApEntity{
 			ID:                 request.ID,
 			CCID:               ccid,
-			Publickey:          string(pubKeyPEM),
-			Privatekey:         string(privKeyPEM),
+			Publickey:          pubKeyPEM,
+			Privatekey:         privKeyPEM,
+			KeyType:            keyType,
 			HomeStream:         request.HomeStream,
 			NotificationStream: request.NotificationStream,
 			FollowStream:       request.FollowStream,
+			AlsoKnownAs:        request.AlsoKnownAs,
 		})
 		if err != nil {
 			span.RecordError(err)
@@ -966,6 +1877,10 @@ func (h Handler) NodeInfoWellKnown(c echo.Context) error {
 				Rel:  "http://nodeinfo.diaspora.software/ns/schema/2.0",
 				Href: "https://" + h.config.Concurrent.FQDN + "/ap/nodeinfo/2.0",
 			},
+			{
+				Rel:  "http://nodeinfo.diaspora.software/ns/schema/2.1",
+				Href: "https://" + h.config.Concurrent.FQDN + "/ap/nodeinfo/2.1",
+			},
 		},
 	})
 }
@@ -1058,6 +1973,110 @@ func (h Handler) NodeInfo(c echo.Context) error {
 	})
 }
 
+// NodeInfo21 handles NodeInfo 2.1 requests. It differs from NodeInfo in
+// protocol list, in adding the software repository and homepage, and in
+// taking its usage counts (total, activeMonth and activeHalfyear users,
+// localPosts, localComments) from the bridge's own repository.
+func (h Handler) NodeInfo21(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "NodeInfo21")
+	defer span.End()
+
+	localPosts, err := h.repo.CountLocalPosts(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	users, err := h.repo.CountEntities(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	activeMonth, err := h.repo.CountActiveEntities(ctx, time.Now().AddDate(0, -1, 0))
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	activeHalfyear, err := h.repo.CountActiveEntities(ctx, time.Now().AddDate(0, -6, 0))
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	localComments, err := h.repo.CountLocalComments(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	return c.JSON(http.StatusOK, NodeInfo{
+		Version: "2.1",
+		Software: NodeInfoSoftware{
+			Name:       "Concurrent",
+			Version:    h.version,
+			Repository: "https://github.com/totegamma/concurrent",
+			Homepage:   "https://concrnt.world",
+		},
+		Protocols: []string{
+			"activitypub",
+		},
+		OpenRegistrations: h.config.Concurrent.Registration == "open",
+		Metadata: NodeInfoMetadata{
+			NodeName:        h.config.Profile.Nickname,
+			NodeDescription: h.config.Profile.Description,
+			Maintainer: NodeInfoMetadataMaintainer{
+				Name:  h.config.Profile.MaintainerName,
+				Email: h.config.Profile.MaintainerEmail,
+			},
+			ThemeColor: h.config.Profile.ThemeColor,
+		},
+		Usage: NodeInfoUsage{
+			Users: NodeInfoUsers{
+				TotalUsers:     users,
+				ActiveMonth:    activeMonth,
+				ActiveHalfyear: activeHalfyear,
+			},
+			LocalPosts:    localPosts,
+			LocalComments: localComments,
+		},
+	})
+}
+
+// InstanceV1 handles the Mastodon-compatible `/api/v1/instance` endpoint,
+// so third-party Mastodon-family clients can introspect the bridge.
+func (h Handler) InstanceV1(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "InstanceV1")
+	defer span.End()
+
+	localPosts, err := h.repo.CountLocalPosts(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	users, err := h.repo.CountEntities(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	return c.JSON(http.StatusOK, InstanceV1{
+		URI:              h.config.Concurrent.FQDN,
+		Title:            h.config.Profile.Nickname,
+		ShortDescription: h.config.Profile.Description,
+		Description:      h.config.Profile.Description,
+		Email:            h.config.Profile.MaintainerEmail,
+		Version:          h.version,
+		Registrations:    h.config.Concurrent.Registration == "open",
+		Stats: InstanceV1Stats{
+			UserCount:   users,
+			StatusCount: localPosts,
+		},
+	})
+}
+
 // Import handles import requests.
 func (h Handler) ImportNote(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "ImportNote")
@@ -1104,7 +2123,7 @@ func (h Handler) ImportNote(c echo.Context) error {
 	}
 
 	// save note as concurrent message
-	created, err := h.NoteToMessage(ctx, note, person, []string{})
+	created, err := h.NoteToMessage(ctx, note, person, []string{}, false)
 	if err != nil {
 		log.Println(err)
 		span.RecordError(err)
@@ -1125,6 +2144,70 @@ func (h Handler) ImportNote(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": created})
 }
 
+// GetPendingDeliveries handles requests to inspect deliveries that are neither delivered nor dead-lettered.
+func (h Handler) GetPendingDeliveries(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "GetPendingDeliveries")
+	defer span.End()
+
+	deliveries, err := h.repo.GetPendingDeliveries(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": deliveries})
+}
+
+// GetDeadDeliveries handles requests to inspect dead-lettered deliveries.
+func (h Handler) GetDeadDeliveries(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "GetDeadDeliveries")
+	defer span.End()
+
+	deliveries, err := h.repo.GetDeadDeliveries(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": deliveries})
+}
+
+// RequeueDelivery handles requests to retry a dead-lettered delivery.
+func (h Handler) RequeueDelivery(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "RequeueDelivery")
+	defer span.End()
+
+	id := c.Param("id")
+	delivery, err := h.repo.GetDeliveryByID(ctx, parseUint(id))
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "delivery not found")
+	}
+
+	delivery.Dead = false
+	delivery.Attempt = 0
+	delivery.LastError = ""
+	delivery.NextAttempt = time.Now()
+
+	updated, err := h.repo.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok", "content": updated})
+}
+
+// parseUint parses a route parameter into a uint, returning 0 on failure so
+// the lookup it feeds simply reports "not found".
+func parseUint(s string) uint {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(v)
+}
+
 // PrintRequest prints the request body.
 func (h Handler) PrintRequest(c echo.Context) error {
 
@@ -0,0 +1,64 @@
+package activitypub
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/totegamma/concurrent/x/jwt"
+)
+
+// HostMeta serves the /.well-known/host-meta XRD document advertising this
+// instance's WebFinger endpoint. It's the fallback remote servers probe
+// when they can't resolve an acct: URI directly (see
+// fetchWebfingerViaHostMeta in client.go), and it's what authorize_interaction
+// below relies on remote instances serving for the reverse direction.
+func (h Handler) HostMeta(c echo.Context) error {
+	_, span := tracer.Start(c.Request().Context(), "HostMeta")
+	defer span.End()
+
+	c.Response().Header().Set("Content-Type", "application/xrd+xml")
+	return c.XML(http.StatusOK, HostMeta{
+		Links: []HostMetaLink{
+			{
+				Rel:      "lrdd",
+				Type:     "application/xrd+xml",
+				Template: "https://" + h.config.Concurrent.FQDN + "/.well-known/webfinger?resource={uri}",
+			},
+		},
+	})
+}
+
+// AuthorizeInteraction implements the OStatus-era `authorize_interaction`
+// endpoint that Mastodon-family "Follow" buttons link to: the `subscribe`
+// template this bridge advertises in WebFinger (see Handler.WebFinger)
+// points here, so when a viewer clicks "Follow" on someone else's profile
+// while logged into this instance, their browser lands here with `uri`
+// naming the account they were looking at. It resolves that account and
+// issues the follow through the exact same path as POST /ap/api/follow/:id.
+func (h Handler) AuthorizeInteraction(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "AuthorizeInteraction")
+	defer span.End()
+
+	claims := c.Get("jwtclaims").(jwt.Claims)
+	ccid := claims.Issuer
+	entity, err := h.repo.GetEntityByCCID(ctx, ccid)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusNotFound, "entity not found")
+	}
+
+	uri := c.QueryParam("uri")
+	if uri == "" {
+		return c.String(http.StatusBadRequest, "Invalid uri")
+	}
+	uri = strings.TrimPrefix(uri, "acct:")
+
+	follow, err := h.followActor(ctx, entity, uri)
+	if err != nil {
+		span.RecordError(err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	return c.Redirect(http.StatusFound, follow.PublisherPersonURL)
+}
@@ -0,0 +1,181 @@
+package activitypub
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+var breakerOpenGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "ccapi_ap_circuit_breaker_open",
+	Help: "Whether the circuit breaker for a remote host is currently open (1) or closed (0).",
+}, []string{"host"})
+
+const (
+	// clientTimeout bounds how long a single outbound request may hang.
+	clientTimeout = 30 * time.Second
+
+	// perHostRate caps steady-state outbound requests per remote host.
+	perHostRate  = rate.Limit(5) // requests per second
+	perHostBurst = 10
+
+	breakerFailureThreshold = 5
+	breakerCooldown         = 5 * time.Minute
+
+	// maxInlineRetryAfter bounds how long Do will block the caller's
+	// goroutine retrying a 429/503 inline. A Retry-After beyond this is
+	// left to StartDeliveryWorker's own backoff/reschedule instead, so a
+	// host asking for a long wait can't stall a whole batch of deliveries.
+	maxInlineRetryAfter = 5 * time.Second
+)
+
+// hostBreaker tracks consecutive failures for a single remote host.
+type hostBreaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// Client is a shared, politeness-aware HTTP client for outbound federation
+// traffic: one pooled transport, a per-host token-bucket limiter, automatic
+// Retry-After handling on 429/503, and a per-host circuit breaker so a dead
+// instance is skipped for a cooldown period instead of hammered.
+type Client struct {
+	hc *http.Client
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	breakers map[string]*hostBreaker
+}
+
+// NewClient returns a new shared Client.
+func NewClient() *Client {
+	return &Client{
+		hc: &http.Client{
+			Timeout: clientTimeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		limiters: make(map[string]*rate.Limiter),
+		breakers: make(map[string]*hostBreaker),
+	}
+}
+
+func (c *Client) limiterFor(host string) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	limiter, ok := c.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(perHostRate, perHostBurst)
+		c.limiters[host] = limiter
+	}
+	return limiter
+}
+
+// breakerOpen reports whether host is currently inside its cooldown window.
+func (c *Client) breakerOpen(host string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	breaker, ok := c.breakers[host]
+	if !ok {
+		return false
+	}
+	return time.Now().Before(breaker.openUntil)
+}
+
+func (c *Client) recordSuccess(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.breakers, host)
+	breakerOpenGauge.WithLabelValues(host).Set(0)
+}
+
+func (c *Client) recordFailure(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	breaker, ok := c.breakers[host]
+	if !ok {
+		breaker = &hostBreaker{}
+		c.breakers[host] = breaker
+	}
+	breaker.failures++
+	if breaker.failures >= breakerFailureThreshold {
+		breaker.openUntil = time.Now().Add(breakerCooldown)
+		breakerOpenGauge.WithLabelValues(host).Set(1)
+	}
+}
+
+// Do executes req through the shared transport, respecting the per-host rate
+// limiter and circuit breaker, and retrying once inline if the remote asks
+// us to back off via a short Retry-After. A longer Retry-After is left
+// unretried here: the response is returned as-is and StartDeliveryWorker's
+// normal backoff schedule reschedules the delivery instead of blocking.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	if c.breakerOpen(host) {
+		return nil, &DeliveryError{StatusCode: 0, Message: "circuit breaker open for host: " + host}
+	}
+
+	if err := c.limiterFor(host).Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		c.recordFailure(host)
+		return nil, err
+	}
+
+	if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) && (req.Body == nil || req.GetBody != nil) {
+		if wait := parseRetryAfter(resp.Header.Get("Retry-After")); wait > 0 && wait <= maxInlineRetryAfter {
+			resp.Body.Close()
+			time.Sleep(wait)
+
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					c.recordFailure(host)
+					return nil, err
+				}
+				req.Body = body
+			}
+			resp, err = c.hc.Do(req)
+			if err != nil {
+				c.recordFailure(host)
+				return nil, err
+			}
+		}
+	}
+
+	if resp.StatusCode >= 500 {
+		c.recordFailure(host)
+	} else {
+		c.recordSuccess(host)
+	}
+
+	return resp, nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds, returning 0 if absent or invalid.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(header)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
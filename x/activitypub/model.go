@@ -2,10 +2,12 @@ package activitypub
 
 import (
 	"encoding/hex"
+	"encoding/xml"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/go-yaml/yaml"
 	"log"
 	"os"
+	"time"
 )
 
 // ApEntity is a db model of an ActivityPub entity.
@@ -14,9 +16,12 @@ type ApEntity struct {
 	CCID               string `json:"ccid" gorm:"type:char(42)"`
 	Publickey          string `json:"publickey" gorm:"type:text"`
 	Privatekey         string `json:"privatekey" gorm:"type:text"`
+	KeyType            string `json:"keytype" gorm:"type:text"` // "rsa" (default) or "ed25519"
 	HomeStream         string `json:"homestream" gorm:"type:text"`
 	NotificationStream string `json:"notificationstream" gorm:"type:text"`
 	FollowStream       string `json:"followstream" gorm:"type:text"`
+	AlsoKnownAs        string `json:"alsoknownas" gorm:"type:text"` // comma-separated actor IRIs signed into this entity's actor document
+	MovedTo            string `json:"movedto" gorm:"type:text"`     // actor IRI this entity has migrated to, if any
 }
 
 // ApPerson is a db model of an ActivityPub entity.
@@ -39,16 +44,53 @@ type ApFollow struct {
 // ApFollwer is a db model of an ActivityPub follower.
 // Activitypub -> Concurrent
 type ApFollower struct {
-	ID                  string `json:"id" gorm:"type:text"`
-	SubscriberPersonURL string `json:"subscriber" gorm:"type:text;uniqueIndex:uniq_apfollower;"` // ActivityPub Person
-	PublisherUserID     string `json:"publisher" gorm:"type:text;uniqueIndex:uniq_apfollower;"`  // Concurrent APID
-	SubscriberInbox     string `json:"subscriber_inbox" gorm:"type:text"`                        // ActivityPub Inbox
+	ID                    string    `json:"id" gorm:"type:text"`
+	SubscriberPersonURL   string    `json:"subscriber" gorm:"type:text;uniqueIndex:uniq_apfollower;"` // ActivityPub Person
+	PublisherUserID       string    `json:"publisher" gorm:"type:text;uniqueIndex:uniq_apfollower;"`  // Concurrent APID
+	SubscriberInbox       string    `json:"subscriber_inbox" gorm:"type:text"`                        // ActivityPub Inbox
+	SubscriberSharedInbox string    `json:"subscriber_shared_inbox" gorm:"type:text"`                 // ActivityPub sharedInbox, if the actor advertises one
+	LastDeliveredAt       time.Time `json:"lastDeliveredAt" gorm:"autoCreateTime"`                     // cursor: this follower has seen every publisher post up to this time
 }
 
 // ApObjectReference is a db model of an ActivityPub object cross reference.
 type ApObjectReference struct {
-	ApObjectID string `json:"apobjectID" gorm:"primaryKey;type:text;"`
-	CcObjectID string `json:"ccobjectID" gorm:"type:text;"`
+	ApObjectID   string    `json:"apobjectID" gorm:"primaryKey;type:text;"`
+	CcObjectID   string    `json:"ccobjectID" gorm:"type:text;"`
+	EntityID     string    `json:"entityID" gorm:"type:text;index"`        // local entity this reference was published by, if any; empty for references we only mirror in
+	AttributedTo string    `json:"attributedTo" gorm:"type:text"`          // remote actor IRI that authored this mirrored note, if any; an inbound Update must come from this actor
+	IsReply      bool      `json:"isReply" gorm:"type:bool;default:false"` // true if the referenced note is a reply to another note
+	CreatedAt    time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// ApForwarded tracks activity URIs we have already forwarded to our
+// followers, so inbox forwarding never re-sends the same activity twice.
+type ApForwarded struct {
+	ActivityID string    `json:"activityID" gorm:"primaryKey;type:text"`
+	ForwardedAt time.Time `json:"forwardedAt"`
+}
+
+// ApObjectSubscription records that a remote inbox has interacted with
+// (replied to or announced) one of our objects, so future activities
+// referencing that object can be forwarded to it even if it isn't a
+// follower of the object's author.
+type ApObjectSubscription struct {
+	ID              uint   `json:"id" gorm:"primaryKey"`
+	ApObjectID      string `json:"apobjectID" gorm:"type:text;uniqueIndex:uniq_apobjectsubscription"`
+	SubscriberInbox string `json:"subscriberInbox" gorm:"type:text;uniqueIndex:uniq_apobjectsubscription"`
+}
+
+// ApDelivery is a db model of a queued outbound ActivityPub delivery.
+// It is consumed by the delivery worker, which retries with backoff on
+// transient failure and dead-letters jobs that exhaust their attempts.
+type ApDelivery struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Inbox       string    `json:"inbox" gorm:"type:text;index:idx_ap_deliveries_inbox"`
+	Payload     string    `json:"payload" gorm:"type:text"`
+	EntityID    string    `json:"entityID" gorm:"type:text"` // ApEntity.ID used to sign the request
+	Attempt     int       `json:"attempt" gorm:"type:integer;default:0"`
+	NextAttempt time.Time `json:"nextAttempt" gorm:"index:idx_ap_deliveries_next_attempt"`
+	LastError   string    `json:"lastError" gorm:"type:text"`
+	Dead        bool      `json:"dead" gorm:"type:bool;default:false"`
 }
 
 // WellKnown is a struct for a well-known response.
@@ -71,9 +113,23 @@ type WebFinger struct {
 
 // WebFingerLink is a struct for the links field of a WebFinger response.
 type WebFingerLink struct {
-	Rel  string `json:"rel"`
-	Type string `json:"type"`
-	Href string `json:"href"`
+	Rel      string `json:"rel"`
+	Type     string `json:"type,omitempty"`
+	Href     string `json:"href,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+// HostMeta is a struct for a /.well-known/host-meta XRD response.
+type HostMeta struct {
+	XMLName xml.Name       `xml:"XRD"`
+	Links   []HostMetaLink `xml:"Link"`
+}
+
+// HostMetaLink is a struct for the Link element of a host-meta XRD response.
+type HostMetaLink struct {
+	Rel      string `xml:"rel,attr"`
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
 }
 
 // endpoints is a struct for the endpoints field of a WebFinger response.
@@ -99,6 +155,32 @@ type Person struct {
 	URL               string          `json:"url,omitempty"`
 	Icon              Icon            `json:"icon,omitempty"`
 	PublicKey         Key             `json:"publicKey,omitempty"`
+	AlsoKnownAs       []string        `json:"alsoKnownAs,omitempty"`
+	MovedTo           string          `json:"movedTo,omitempty"`
+}
+
+// OrderedCollection is a struct for an ActivityPub OrderedCollection, used
+// to advertise the size and paging endpoints of a followers/following/
+// outbox collection without enumerating its items.
+type OrderedCollection struct {
+	Context    interface{} `json:"@context,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	TotalItems int64       `json:"totalItems"`
+	First      string      `json:"first,omitempty"`
+	Last       string      `json:"last,omitempty"`
+}
+
+// OrderedCollectionPage is a struct for a single page of an
+// OrderedCollection, addressed by the `?page=N` query parameter.
+type OrderedCollectionPage struct {
+	Context      interface{} `json:"@context,omitempty"`
+	ID           string      `json:"id,omitempty"`
+	Type         string      `json:"type,omitempty"`
+	PartOf       string      `json:"partOf,omitempty"`
+	Next         string      `json:"next,omitempty"`
+	Prev         string      `json:"prev,omitempty"`
+	OrderedItems interface{} `json:"orderedItems"`
 }
 
 // Key is a struct for the publicKey field of an actor.
@@ -123,6 +205,7 @@ type Create struct {
 	Type    string      `json:"type,omitempty"`
 	Actor   string      `json:"actor,omitempty"`
 	To      []string    `json:"to,omitempty"`
+	Cc      []string    `json:"cc,omitempty"` // followers this activity was actually fanned out to, so a shared inbox can resolve its own recipients out of one shared delivery
 	Object  interface{} `json:"object,omitempty"`
 }
 
@@ -134,7 +217,9 @@ type Object struct {
 	Content    string       `json:"content,omitempty"`
 	Actor      string       `json:"actor,omitempty"`
 	Object     interface{}  `json:"object,omitempty"`
+	Target     string       `json:"target,omitempty"`
 	To         []string     `json:"to,omitempty"`
+	Cc         []string     `json:"cc,omitempty"`
 	Attachment []Attachment `json:"attachment,omitempty"`
 	Tag        []Tag        `json:"tag,omitempty"`
 }
@@ -144,6 +229,10 @@ type Attachment struct {
 	Type      string `json:"type,omitempty"`
 	MediaType string `json:"mediaType,omitempty"`
 	URL       string `json:"url,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Blurhash  string `json:"blurhash,omitempty"`
 }
 
 // Tag is a struct for an ActivityPub tag.
@@ -169,6 +258,13 @@ type CreateEntityRequest struct {
 	HomeStream         string `json:"homestream" gorm:"type:text"`
 	NotificationStream string `json:"notificationstream" gorm:"type:text"`
 	FollowStream       string `json:"followstream" gorm:"type:text"`
+	KeyType            string `json:"keytype"` // "rsa" (default) or "ed25519"
+	AlsoKnownAs        string `json:"alsoknownas"`
+}
+
+// MoveRequest is a struct for a request to migrate an entity to another actor.
+type MoveRequest struct {
+	Target string `json:"target"`
 }
 
 type ApAccountStats struct {
@@ -186,6 +282,7 @@ type Note struct {
 	QuoteURL     string       `json:"quoteUrl,omitempty"`
 	Content      string       `json:"content,omitempty"`
 	Published    string       `json:"published,omitempty"`
+	Updated      string       `json:"updated,omitempty"`
 	To           []string     `json:"to,omitempty"`
 	Tag          []Tag        `json:"tag,omitempty"`
 	Attachment   []Attachment `json:"attachment,omitempty"`
@@ -195,12 +292,15 @@ type Note struct {
 }
 
 type NodeInfoUsers struct {
-	TotalUsers int64 `json:"total,omitempty"`
+	TotalUsers     int64 `json:"total,omitempty"`
+	ActiveMonth    int64 `json:"activeMonth,omitempty"`
+	ActiveHalfyear int64 `json:"activeHalfyear,omitempty"`
 }
 
 type NodeInfoUsage struct {
-	LocalPosts int64         `json:"localPosts,omitempty"`
-	Users      NodeInfoUsers `json:"users,omitempty"`
+	LocalPosts    int64         `json:"localPosts,omitempty"`
+	LocalComments int64         `json:"localComments,omitempty"`
+	Users         NodeInfoUsers `json:"users,omitempty"`
 }
 
 // NodeInfo is a struct for a NodeInfo response.
@@ -215,8 +315,10 @@ type NodeInfo struct {
 
 // NodeInfoSoftware is a struct for the software field of a NodeInfo response.
 type NodeInfoSoftware struct {
-	Name    string `json:"name,omitempty"`
-	Version string `json:"version,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Homepage   string `json:"homepage,omitempty"`
 }
 
 // NodeInfoMetadata is a struct for the metadata field of a NodeInfo response.
@@ -233,10 +335,43 @@ type NodeInfoMetadataMaintainer struct {
 	Email string `json:"email,omitempty"`
 }
 
+// InstanceV1 is the subset of the Mastodon v1 `/api/v1/instance` schema we
+// can honestly fill in, so third-party Mastodon-family clients can
+// introspect the bridge without speaking NodeInfo.
+type InstanceV1 struct {
+	URI              string          `json:"uri"`
+	Title            string          `json:"title"`
+	ShortDescription string          `json:"short_description"`
+	Description      string          `json:"description"`
+	Email            string          `json:"email,omitempty"`
+	Version          string          `json:"version"`
+	Registrations    bool            `json:"registrations"`
+	Stats            InstanceV1Stats `json:"stats"`
+}
+
+// InstanceV1Stats is the stats field of an InstanceV1 response.
+type InstanceV1Stats struct {
+	UserCount   int64 `json:"user_count"`
+	StatusCount int64 `json:"status_count"`
+	DomainCount int64 `json:"domain_count"`
+}
+
 type WorldEmoji struct {
 	ImageURL string `json:"imageURL"`
 }
 
+// WorldAttachment is the shape an inbound AP attachment is preserved as in
+// a Concurrent message body, so clients can render a blurhash placeholder
+// and alt text instead of a flattened markdown image link.
+type WorldAttachment struct {
+	URL       string `json:"url"`
+	MediaType string `json:"mediaType,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Blurhash  string `json:"blurhash,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
 type ProxySettings struct {
 	PrivateKey         string `yaml:"privateKey"`
 	NotificationStream string `yaml:"notificationStream"`
@@ -245,11 +380,30 @@ type ProxySettings struct {
 type APConfig struct {
 	Proxy ProxySettings `yaml:"proxy"`
 
+	// RequireSignatures gates HTTP Signature verification on inbound inbox
+	// POSTs. Defaults to false (off) in yaml so existing deployments keep
+	// working until they opt in; set true once federation partners are
+	// known to sign their requests.
+	RequireSignatures bool `yaml:"requireSignatures"`
+
+	// PageSize is the number of items returned per OrderedCollectionPage on
+	// the followers/following/outbox endpoints. Defaults to 20 when unset.
+	PageSize int `yaml:"pageSize"`
+
 	// internal generated
 	ProxyCCID      string
 	ProxyPublicKey string
 }
 
+// pageSize returns the configured followers/following/outbox page size,
+// falling back to a sane default when unset.
+func (c APConfig) pageSize() int {
+	if c.PageSize <= 0 {
+		return 20
+	}
+	return c.PageSize
+}
+
 // Load loads concurrent config from given path
 func (c *APConfig) Load(path string) error {
 	f, err := os.Open(path)
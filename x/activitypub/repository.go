@@ -2,10 +2,14 @@ package activitypub
 
 import (
 	"fmt"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"context"
-	"crypto/rsa"
+	"time"
 	"gorm.io/gorm"
 )
 
@@ -58,6 +62,54 @@ func (r Repository) UpdateEntity(ctx context.Context, entity ApEntity) (ApEntity
 	return entity, result.Error
 }
 
+// CountEntities returns the total number of local entities registered with
+// the bridge.
+func (r Repository) CountEntities(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryCountEntities")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ApEntity{}).Count(&count).Error
+	return count, err
+}
+
+// CountActiveEntities returns the number of local entities that have
+// published at least one message through the bridge since the given time.
+func (r Repository) CountActiveEntities(ctx context.Context, since time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryCountActiveEntities")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&ApObjectReference{}).
+		Where("entity_id <> '' AND created_at >= ?", since).
+		Distinct("entity_id").
+		Count(&count).Error
+	return count, err
+}
+
+// CountLocalPosts returns the number of messages that have been published
+// through the bridge by a local entity.
+func (r Repository) CountLocalPosts(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryCountLocalPosts")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ApObjectReference{}).Where("entity_id <> ''").Count(&count).Error
+	return count, err
+}
+
+// CountLocalComments returns the number of messages published through the
+// bridge by a local entity that are replies to another note.
+func (r Repository) CountLocalComments(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryCountLocalComments")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ApObjectReference{}).Where("entity_id <> '' AND is_reply").Count(&count).Error
+	return count, err
+}
+
 // GetPersonByID returns a person by ID.
 func (r Repository) GetPersonByID(ctx context.Context, id string) (ApPerson, error) {
 	ctx, span := tracer.Start(ctx, "RepositoryGetPersonByID")
@@ -153,6 +205,15 @@ func (r *Repository) GetFollowerByID(ctx context.Context, id string) (ApFollower
 	return follower, result.Error
 }
 
+// UpdateFollowerDeliveryCursor advances a follower's catch-up cursor to at,
+// recording that it has been delivered every publisher post up to that time.
+func (r *Repository) UpdateFollowerDeliveryCursor(ctx context.Context, followerID string, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "RepositoryUpdateFollowerDeliveryCursor")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&ApFollower{}).Where("id = ?", followerID).Update("last_delivered_at", at).Error
+}
+
 // UpdateFollow updates follow
 func (r *Repository) UpdateFollow(ctx context.Context, follow ApFollow) (ApFollow, error) {
 	ctx, span := tracer.Start(ctx, "RepositoryUpdateFollow")
@@ -188,6 +249,87 @@ func (r *Repository) RemoveFollow(ctx context.Context, followID string) (ApFollo
 	return follow, nil
 }
 
+// CountFollowersByUserID returns the number of remote actors following a local entity.
+func (r *Repository) CountFollowersByUserID(ctx context.Context, ownerID string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryCountFollowersByUserID")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ApFollower{}).Where("publisher_user_id = ?", ownerID).Count(&count).Error
+	return count, err
+}
+
+// ListFollowersPaged returns one 1-indexed page of a local entity's followers.
+func (r *Repository) ListFollowersPaged(ctx context.Context, ownerID string, page, pageSize int) ([]ApFollower, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryListFollowersPaged")
+	defer span.End()
+
+	var followers []ApFollower
+	err := r.db.WithContext(ctx).
+		Where("publisher_user_id = ?", ownerID).
+		Order("id asc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&followers).Error
+	return followers, err
+}
+
+// CountFollowsByUserID returns the number of actors a local entity follows.
+func (r *Repository) CountFollowsByUserID(ctx context.Context, ownerID string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryCountFollowsByUserID")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ApFollow{}).Where("subscriber_user_id = ?", ownerID).Count(&count).Error
+	return count, err
+}
+
+// ListFollowsPaged returns one 1-indexed page of a local entity's follows.
+func (r *Repository) ListFollowsPaged(ctx context.Context, ownerID string, page, pageSize int) ([]ApFollow, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryListFollowsPaged")
+	defer span.End()
+
+	var follows []ApFollow
+	err := r.db.WithContext(ctx).
+		Where("subscriber_user_id = ?", ownerID).
+		Order("id asc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&follows).Error
+	return follows, err
+}
+
+// GetFollowersBySubscriber returns every ApFollower row whose remote actor
+// is the given subscriber, regardless of which local entity it follows.
+func (r *Repository) GetFollowersBySubscriber(ctx context.Context, subscriber string) ([]ApFollower, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryGetFollowersBySubscriber")
+	defer span.End()
+
+	var followers []ApFollower
+	err := r.db.WithContext(ctx).Where("subscriber_person_url = ?", subscriber).Find(&followers).Error
+	return followers, err
+}
+
+// RemoveFollowersByInbox removes every ApFollower row addressed through the
+// given inbox (personal or shared), used when a delivery comes back 410 Gone
+// to stop retrying a follower that no longer exists on the remote server.
+func (r *Repository) RemoveFollowersByInbox(ctx context.Context, inbox string) error {
+	ctx, span := tracer.Start(ctx, "RepositoryRemoveFollowersByInbox")
+	defer span.End()
+
+	return r.db.WithContext(ctx).
+		Where("subscriber_inbox = ? OR subscriber_shared_inbox = ?", inbox, inbox).
+		Delete(&ApFollower{}).Error
+}
+
+// RemoveFollowerByID removes a single ApFollower row by its activity ID.
+func (r *Repository) RemoveFollowerByID(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "RepositoryRemoveFollowerByID")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&ApFollower{}).Error
+}
+
 // Remove Follower action
 func (r *Repository) RemoveFollower(ctx context.Context, local, remote string) (ApFollower, error) {
 	ctx, span := tracer.Start(ctx, "RepositoryRemoveFollower")
@@ -242,6 +384,48 @@ func (r *Repository) GetApObjectReferenceByCcObjectID(ctx context.Context, ccObj
 	return references, err
 }
 
+// CountApObjectReferencesByEntityID returns the number of local messages a
+// local entity has published through the bridge.
+func (r *Repository) CountApObjectReferencesByEntityID(ctx context.Context, entityID string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryCountApObjectReferencesByEntityID")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ApObjectReference{}).Where("entity_id = ?", entityID).Count(&count).Error
+	return count, err
+}
+
+// ListApObjectReferencesByEntityIDPaged returns one 1-indexed page of a
+// local entity's published messages, most recently created first.
+func (r *Repository) ListApObjectReferencesByEntityIDPaged(ctx context.Context, entityID string, page, pageSize int) ([]ApObjectReference, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryListApObjectReferencesByEntityIDPaged")
+	defer span.End()
+
+	var references []ApObjectReference
+	err := r.db.WithContext(ctx).
+		Where("entity_id = ?", entityID).
+		Order("created_at desc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&references).Error
+	return references, err
+}
+
+// ListApObjectReferencesByEntityIDAfter returns a local entity's published
+// messages created after the given time, oldest first, so a catching-up
+// follower can be replayed the posts it missed in order.
+func (r *Repository) ListApObjectReferencesByEntityIDAfter(ctx context.Context, entityID string, after time.Time) ([]ApObjectReference, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryListApObjectReferencesByEntityIDAfter")
+	defer span.End()
+
+	var references []ApObjectReference
+	err := r.db.WithContext(ctx).
+		Where("entity_id = ? AND created_at > ?", entityID, after).
+		Order("created_at asc").
+		Find(&references).Error
+	return references, err
+}
+
 // DeleteApObjectReference deletes reference by ap object ID
 func (r *Repository) DeleteApObjectReference(ctx context.Context, ApObjectID string) error {
 	ctx, span := tracer.Start(ctx, "RepositoryDeleteApObjectReference")
@@ -250,17 +434,194 @@ func (r *Repository) DeleteApObjectReference(ctx context.Context, ApObjectID str
 	return r.db.WithContext(ctx).Where("ap_object_id = ?", ApObjectID).Delete(&ApObjectReference{}).Error
 }
 
-func (r *Repository) LoadKey(ctx context.Context, entity ApEntity) (*rsa.PrivateKey, error) {
+// GetForwarded returns the forwarding record for an activity, if we have
+// already forwarded it.
+func (r *Repository) GetForwarded(ctx context.Context, activityID string) (ApForwarded, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryGetForwarded")
+	defer span.End()
+
+	var forwarded ApForwarded
+	result := r.db.WithContext(ctx).Where("activity_id = ?", activityID).First(&forwarded)
+	return forwarded, result.Error
+}
+
+// CreateForwarded records that an activity has been forwarded, so it is not forwarded again.
+func (r *Repository) CreateForwarded(ctx context.Context, forwarded ApForwarded) error {
+	ctx, span := tracer.Start(ctx, "RepositoryCreateForwarded")
+	defer span.End()
+
+	forwarded.ForwardedAt = time.Now()
+	return r.db.WithContext(ctx).Create(&forwarded).Error
+}
+
+// AddObjectSubscription records that a remote inbox has interacted with one of our objects.
+func (r *Repository) AddObjectSubscription(ctx context.Context, apObjectID, subscriberInbox string) error {
+	ctx, span := tracer.Start(ctx, "RepositoryAddObjectSubscription")
+	defer span.End()
+
+	return r.db.WithContext(ctx).
+		Where(ApObjectSubscription{ApObjectID: apObjectID, SubscriberInbox: subscriberInbox}).
+		FirstOrCreate(&ApObjectSubscription{ApObjectID: apObjectID, SubscriberInbox: subscriberInbox}).Error
+}
+
+// RemoveObjectSubscription removes a remote inbox's subscription to one of our objects.
+func (r *Repository) RemoveObjectSubscription(ctx context.Context, apObjectID, subscriberInbox string) error {
+	ctx, span := tracer.Start(ctx, "RepositoryRemoveObjectSubscription")
+	defer span.End()
+
+	return r.db.WithContext(ctx).
+		Where("ap_object_id = ? AND subscriber_inbox = ?", apObjectID, subscriberInbox).
+		Delete(&ApObjectSubscription{}).Error
+}
+
+// ListObjectSubscribers returns the inboxes subscribed to one of our objects.
+func (r *Repository) ListObjectSubscribers(ctx context.Context, apObjectID string) ([]ApObjectSubscription, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryListObjectSubscribers")
+	defer span.End()
+
+	var subscriptions []ApObjectSubscription
+	err := r.db.WithContext(ctx).Where("ap_object_id = ?", apObjectID).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// CreateDelivery queues a new outbound delivery job.
+func (r *Repository) CreateDelivery(ctx context.Context, delivery ApDelivery) (ApDelivery, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryCreateDelivery")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Create(&delivery)
+	return delivery, result.Error
+}
+
+// GetDueDeliveries returns up to limit non-dead deliveries whose next attempt is due.
+func (r *Repository) GetDueDeliveries(ctx context.Context, limit int) ([]ApDelivery, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryGetDueDeliveries")
+	defer span.End()
+
+	var deliveries []ApDelivery
+	err := r.db.WithContext(ctx).
+		Where("dead = ? AND next_attempt <= ?", false, time.Now()).
+		Order("next_attempt asc").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetPendingDeliveries returns every non-dead delivery (not yet attempted,
+// due now, or waiting for a retry), soonest next attempt first, for admin
+// inspection.
+func (r *Repository) GetPendingDeliveries(ctx context.Context) ([]ApDelivery, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryGetPendingDeliveries")
+	defer span.End()
+
+	var deliveries []ApDelivery
+	err := r.db.WithContext(ctx).Where("dead = ?", false).Order("next_attempt asc").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetDeadDeliveries returns dead-lettered deliveries for inspection.
+func (r *Repository) GetDeadDeliveries(ctx context.Context) ([]ApDelivery, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryGetDeadDeliveries")
+	defer span.End()
+
+	var deliveries []ApDelivery
+	err := r.db.WithContext(ctx).Where("dead = ?", true).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// UpdateDelivery persists retry bookkeeping (attempt count, backoff, last error) for a delivery.
+func (r *Repository) UpdateDelivery(ctx context.Context, delivery ApDelivery) (ApDelivery, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryUpdateDelivery")
+	defer span.End()
+
+	result := r.db.WithContext(ctx).Save(&delivery)
+	return delivery, result.Error
+}
+
+// DeleteDelivery removes a delivery job once it has been delivered.
+func (r *Repository) DeleteDelivery(ctx context.Context, id uint) error {
+	ctx, span := tracer.Start(ctx, "RepositoryDeleteDelivery")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Delete(&ApDelivery{}, id).Error
+}
+
+// GetDeliveryByID returns a single delivery job by ID.
+func (r *Repository) GetDeliveryByID(ctx context.Context, id uint) (ApDelivery, error) {
+	ctx, span := tracer.Start(ctx, "RepositoryGetDeliveryByID")
+	defer span.End()
+
+	var delivery ApDelivery
+	result := r.db.WithContext(ctx).First(&delivery, id)
+	return delivery, result.Error
+}
+
+// LoadKey parses an entity's stored private key. It supports legacy PKCS#1
+// RSA keys as well as PKCS#8 keys (RSA or Ed25519), returning whichever
+// concrete type the PEM block decodes to so callers can hand it straight to
+// httpsig, which picks the strongest algorithm the key supports.
+func (r *Repository) LoadKey(ctx context.Context, entity ApEntity) (crypto.PrivateKey, error) {
 	block, _ := pem.Decode([]byte(entity.Privatekey))
 	if block == nil {
-		return &rsa.PrivateKey{}, fmt.Errorf("failed to parse PEM block containing the key")
+		return nil, fmt.Errorf("failed to parse PEM block containing the key")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PKCS8 private key: " + err.Error())
+		}
+		return key, nil
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DER encoded private key: " + err.Error())
+		}
+		return priv, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key block type: %s", block.Type)
 	}
+}
+
+// GenerateKeyPair creates a new PEM-encoded keypair for a local actor.
+// keyType selects "ed25519" for new-style keys; anything else (including
+// "") falls back to the legacy RSA-2048 keys existing actors already use.
+func GenerateKeyPair(keyType string) (pubPEM string, privPEM string, err error) {
+	if keyType == "ed25519" {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+
+		privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return "", "", err
+		}
+		pubBytes, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", "", err
+		}
+
+		privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes})
+		pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})
+		return string(pubPEM), string(privPEM), nil
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+
+	privBytes := x509.MarshalPKCS1PrivateKey(priv)
+	privPEMBlock := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: privBytes})
 
-	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
 	if err != nil {
-		return &rsa.PrivateKey{}, fmt.Errorf("failed to parse DER encoded private key: " + err.Error())
+		return "", "", err
 	}
+	pubPEMBlock := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})
 
-	return priv, nil
+	return string(pubPEMBlock), string(privPEMBlock), nil
 }
 
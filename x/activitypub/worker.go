@@ -4,13 +4,75 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
 	"github.com/totegamma/concurrent/x/association"
 	"github.com/totegamma/concurrent/x/core"
 	"github.com/totegamma/concurrent/x/message"
 )
 
+var (
+	deliveriesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccapi_ap_deliveries_sent_total",
+		Help: "Total number of ActivityPub deliveries that were successfully sent.",
+	})
+	deliveriesFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccapi_ap_deliveries_failed_total",
+		Help: "Total number of ActivityPub deliveries that were abandoned or dead-lettered.",
+	})
+	deliveriesRetried = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccapi_ap_deliveries_retried_total",
+		Help: "Total number of ActivityPub deliveries that were rescheduled for retry.",
+	})
+	deliveriesQueued = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ccapi_ap_deliveries_queued_total",
+		Help: "Total number of ActivityPub deliveries that were enqueued for delivery.",
+	})
+)
+
+// deliveryBackoffSchedule is the delay before each retry attempt, following
+// the fediverse convention (~1m, 5m, 25m, ... capped at ~2 days).
+var deliveryBackoffSchedule = []time.Duration{
+	1 * time.Minute,
+	5 * time.Minute,
+	25 * time.Minute,
+	2 * time.Hour,
+	12 * time.Hour,
+	48 * time.Hour,
+}
+
+// maxDeliveryAttempts counts the first try plus one retry per schedule entry.
+const maxDeliveryAttempts = len(deliveryBackoffSchedule) + 1
+
+func deliveryBackoff(attempt int) time.Duration {
+	if attempt >= len(deliveryBackoffSchedule) {
+		return deliveryBackoffSchedule[len(deliveryBackoffSchedule)-1]
+	}
+	return deliveryBackoffSchedule[attempt]
+}
+
+// isRetryableStatus reports whether a failed delivery should be retried
+// rather than abandoned, following standard fediverse conventions.
+func isRetryableStatus(statusCode int) bool {
+	if statusCode == 0 { // network error / timeout
+		return true
+	}
+	if statusCode >= 500 {
+		return true
+	}
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests
+}
+
+// StartMessageWorker subscribes to each local entity's home stream and, on
+// every new message, fans it out to all of that entity's followers in one
+// batch: followers sharing a remote sharedInbox collapse into a single
+// EnqueueDelivery job instead of one per follower row.
 func (h *Handler) StartMessageWorker() {
 
 	ticker10 := time.NewTicker(10 * time.Second)
@@ -21,129 +83,186 @@ func (h *Handler) StartMessageWorker() {
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 
-		jobs, err := h.repo.GetAllFollowers(ctx)
+		follows, err := h.repo.GetAllFollowers(ctx)
 		if err != nil {
 			log.Printf("error: %v", err)
 		}
 
-		for _, job := range jobs {
-			if _, ok := workers[job.ID]; !ok {
-				log.Printf("start worker %v", job.ID)
-				ctx, cancel := context.WithCancel(context.Background())
-				workers[job.ID] = cancel
+		// one worker per publisher, not per follower row, since a single
+		// EnqueueDelivery call now fans a message out to all of its followers
+		seenPublisher := make(map[string]bool)
+		var entities []ApEntity
+		for _, follow := range follows {
+			if seenPublisher[follow.PublisherUserID] {
+				continue
+			}
+			seenPublisher[follow.PublisherUserID] = true
 
-				entity, err := h.repo.GetEntityByID(ctx, job.PublisherUserID)
-				if err != nil {
-					log.Printf("error: %v", err)
-				}
-				ownerID := entity.CCID
-				home := entity.HomeStream
-				if home == "" {
-					continue
-				}
-				if entity.MovedTo != "" {
-					continue
-				}
-				pubsub := h.rdb.Subscribe(ctx)
-				pubsub.Subscribe(ctx, home)
-
-				go func(ctx context.Context, job ApFollower) {
-					for {
-						select {
-						case <-ctx.Done():
-							log.Printf("worker %v done", job.ID)
-							return
-						default:
-							pubsubMsg, err := pubsub.ReceiveMessage(ctx)
-							if ctx.Err() != nil {
-								continue
-							}
-							if err != nil {
-								log.Printf("error: %v", err)
-								continue
-							}
+			entity, err := h.repo.GetEntityByID(ctx, follow.PublisherUserID)
+			if err != nil {
+				log.Printf("error: %v", err)
+				continue
+			}
+			entities = append(entities, entity)
+		}
 
-							log.Printf("[worker %v] message received!\n", job.ID)
+		for _, entity := range entities {
+			ownerID := entity.ID
+			if _, ok := workers[ownerID]; ok {
+				continue
+			}
+			if entity.HomeStream == "" || entity.MovedTo != "" {
+				continue
+			}
 
-							var streamEvent core.Event
-							err = json.Unmarshal([]byte(pubsubMsg.Payload), &streamEvent)
-							if err != nil {
-								log.Printf("error: %v", err)
-								continue
+			log.Printf("start worker %v", ownerID)
+			ctx, cancel := context.WithCancel(context.Background())
+			workers[ownerID] = cancel
+
+			h.replayMissedDeliveries(ctx, entity)
+
+			pubsub := h.rdb.Subscribe(ctx)
+			pubsub.Subscribe(ctx, entity.HomeStream)
+
+			go func(ctx context.Context, entity ApEntity) {
+				for {
+					select {
+					case <-ctx.Done():
+						log.Printf("worker %v done", entity.ID)
+						return
+					default:
+						pubsubMsg, err := pubsub.ReceiveMessage(ctx)
+						if ctx.Err() != nil {
+							continue
+						}
+						if err != nil {
+							log.Printf("error: %v", err)
+							continue
+						}
+
+						log.Printf("[worker %v] message received!\n", entity.ID)
+
+						var streamEvent core.Event
+						err = json.Unmarshal([]byte(pubsubMsg.Payload), &streamEvent)
+						if err != nil {
+							log.Printf("error: %v", err)
+							continue
+						}
+
+						messageID, ok := streamEvent.Body.(map[string]interface{})["id"].(string)
+						if !ok {
+							log.Printf("streamEvent body read id failed: %v", streamEvent.Body)
+							continue
+						}
+
+						messageAuthor, ok := streamEvent.Body.(map[string]interface{})["author"].(string)
+						if !ok {
+							log.Printf("streamEvent body read author failed: %v", streamEvent.Body)
+							continue
+						}
+
+						if messageAuthor != entity.CCID {
+							log.Printf("message author is not owner: %v", messageAuthor)
+							continue
+						}
+
+						eventType, _ := streamEvent.Body.(map[string]interface{})["type"].(string)
+						if eventType == "delete" {
+							h.deleteOutboundNote(ctx, entity, messageID)
+							continue
+						}
+
+						note, err := h.MessageToNote(ctx, messageID)
+						if err != nil {
+							log.Printf("error: %v", err)
+							continue
+						}
+
+						// record this as one of entity.ID's outbox entries, so
+						// the Outbox handler can list it without needing a
+						// message service listing call
+						_, existingRefErr := h.repo.GetApObjectReferenceByCcObjectID(ctx, messageID)
+						if existingRefErr != nil {
+							if err := h.repo.CreateApObjectReference(ctx, ApObjectReference{
+								ApObjectID: note.ID,
+								CcObjectID: messageID,
+								EntityID:   entity.ID,
+								IsReply:    note.InReplyTo != "",
+							}); err != nil {
+								log.Printf("error recording outbox reference: %v", err)
 							}
+						}
+						isUpdate := eventType == "update" && existingRefErr == nil
 
-							messageID, ok := streamEvent.Body.(map[string]interface{})["id"].(string)
-							if !ok {
-								log.Printf("streamEvent body read id failed: %v", streamEvent.Body)
-								continue
+						followers, err := h.repo.GetFollowers(ctx, entity.ID)
+						if err != nil {
+							log.Printf("error: %v", err)
+							continue
+						}
+						inboxes := make([]string, 0, len(followers))
+						for _, follower := range followers {
+							inboxes = append(inboxes, preferredInbox(follower))
+						}
+						deliveredAt := time.Now()
+
+						if note.Type == "Announce" {
+							announce := Object{
+								Context: []string{"https://www.w3.org/ns/activitystreams"},
+								Type:    "Announce",
+								ID:      "https://" + h.config.Concurrent.FQDN + "/ap/note/" + messageID + "/activity",
+								Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + entity.ID,
+								Content: "",
+								Object:  note.Object,
+								To:      []string{"https://www.w3.org/ns/activitystreams#Public"},
+								Cc:      followerActorIRIs(followers),
 							}
 
-							messageAuthor, ok := streamEvent.Body.(map[string]interface{})["author"].(string)
-							if !ok {
-								log.Printf("streamEvent body read author failed: %v", streamEvent.Body)
+							if err := h.EnqueueDelivery(ctx, inboxes, announce, entity); err != nil {
+								log.Printf("error: %v", err)
 								continue
 							}
+							h.advanceFollowerCursors(ctx, followers, deliveredAt)
+							log.Printf("[worker %v] announce queued for %v followers", entity.ID, len(inboxes))
+						} else {
+
+							activityType := "Create"
+							activitySuffix := "/activity"
+							if isUpdate {
+								activityType = "Update"
+								activitySuffix = "/update"
+								note.Updated = time.Now().UTC().Format(time.RFC3339)
+							}
 
-							if messageAuthor != ownerID {
-								log.Printf("message author is not owner: %v", messageAuthor)
-								continue
+							create := Create{
+								Context: []string{"https://www.w3.org/ns/activitystreams"},
+								Type:    activityType,
+								ID:      "https://" + h.config.Concurrent.FQDN + "/ap/note/" + messageID + activitySuffix,
+								Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + entity.ID,
+								To:      []string{"https://www.w3.org/ns/activitystreams#Public"},
+								Cc:      followerActorIRIs(followers),
+								Object:  note,
 							}
 
-							note, err := h.MessageToNote(ctx, messageID)
-							if err != nil {
+							if err := h.EnqueueDelivery(ctx, inboxes, create, entity); err != nil {
 								log.Printf("error: %v", err)
 								continue
 							}
-
-							if note.Type == "Announce" {
-								announce := Object{
-									Context: []string{"https://www.w3.org/ns/activitystreams"},
-									Type:    "Announce",
-									ID:      "https://" + h.config.Concurrent.FQDN + "/ap/note/" + messageID + "/activity",
-									Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + job.PublisherUserID,
-									Content: "",
-									Object:  note.Object,
-									To:      []string{"https://www.w3.org/ns/activitystreams#Public"},
-								}
-
-								err = h.PostToInbox(ctx, job.SubscriberInbox, announce, entity)
-								if err != nil {
-									log.Printf("error: %v", err)
-									continue
-								}
-								log.Printf("[worker %v] created", job.ID)
-							} else {
-
-								create := Create{
-									Context: []string{"https://www.w3.org/ns/activitystreams"},
-									Type:    "Create",
-									ID:      "https://" + h.config.Concurrent.FQDN + "/ap/note/" + messageID + "/activity",
-									Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + job.PublisherUserID,
-									To:      []string{"https://www.w3.org/ns/activitystreams#Public"},
-									Object:  note,
-								}
-
-								err = h.PostToInbox(ctx, job.SubscriberInbox, create, entity)
-								if err != nil {
-									log.Printf("error: %v", err)
-									continue
-								}
-								log.Printf("[worker %v] created", job.ID)
-							}
+							h.advanceFollowerCursors(ctx, followers, deliveredAt)
+							log.Printf("[worker %v] %v queued for %v followers", entity.ID, activityType, len(inboxes))
 						}
 					}
-				}(ctx, job)
-			}
+				}
+			}(ctx, entity)
 		}
 
-		// create job id list
-		var jobIDs []string
-		for _, job := range jobs {
-			jobIDs = append(jobIDs, job.ID)
+		// create entity id list
+		var entityIDs []string
+		for _, entity := range entities {
+			entityIDs = append(entityIDs, entity.ID)
 		}
 
 		for routineID, cancel := range workers {
-			if !isInList(routineID, jobIDs) {
+			if !isInList(routineID, entityIDs) {
 				log.Printf("cancel worker %v", routineID)
 				cancel()
 				delete(workers, routineID)
@@ -152,6 +271,110 @@ func (h *Handler) StartMessageWorker() {
 	}
 }
 
+// advanceFollowerCursors marks followers as caught up to at, once an
+// activity covering them has been handed to the delivery queue, so a
+// restart's replay doesn't redeliver what the live pubsub path already sent.
+func (h *Handler) advanceFollowerCursors(ctx context.Context, followers []ApFollower, at time.Time) {
+	for _, follower := range followers {
+		if err := h.repo.UpdateFollowerDeliveryCursor(ctx, follower.ID, at); err != nil {
+			log.Printf("error advancing cursor for %v: %v", follower.ID, err)
+		}
+	}
+}
+
+// replayMissedDeliveries runs once when entity's worker starts (for example
+// after a deploy) and replays every post recorded as one of entity's outbox
+// ApObjectReference rows since each follower's last-delivered cursor, so the
+// pubsub subscription below only has to pick up what happens from here on.
+// Only posts a live worker already received and recorded are replayed:
+// messages published while no worker was subscribed have no such row and are
+// not sent, and a Redis outage while the worker runs does not trigger a
+// replay. Activities are
+// replayed oldest-first, and a follower's cursor only advances once the
+// activity covering it has actually been handed to the delivery queue, so a
+// crash mid-replay just repeats the tail of the catch-up on the next start.
+func (h *Handler) replayMissedDeliveries(ctx context.Context, entity ApEntity) {
+	followers, err := h.repo.GetFollowers(ctx, entity.ID)
+	if err != nil {
+		log.Printf("replay %v: error listing followers: %v", entity.ID, err)
+		return
+	}
+	if len(followers) == 0 {
+		return
+	}
+
+	oldest := followers[0].LastDeliveredAt
+	for _, follower := range followers[1:] {
+		if follower.LastDeliveredAt.Before(oldest) {
+			oldest = follower.LastDeliveredAt
+		}
+	}
+
+	refs, err := h.repo.ListApObjectReferencesByEntityIDAfter(ctx, entity.ID, oldest)
+	if err != nil {
+		log.Printf("replay %v: error listing missed posts: %v", entity.ID, err)
+		return
+	}
+
+	for _, ref := range refs {
+		var pending []int
+		for i, follower := range followers {
+			if follower.LastDeliveredAt.Before(ref.CreatedAt) {
+				pending = append(pending, i)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		note, err := h.MessageToNote(ctx, ref.CcObjectID)
+		if err != nil {
+			log.Printf("replay %v: skip %v: %v", entity.ID, ref.CcObjectID, err)
+			continue
+		}
+
+		activityType := "Create"
+		if note.Type == "Announce" {
+			activityType = "Announce"
+		}
+
+		pendingFollowers := make([]ApFollower, 0, len(pending))
+		for _, i := range pending {
+			pendingFollowers = append(pendingFollowers, followers[i])
+		}
+
+		activity := Create{
+			Context: []string{"https://www.w3.org/ns/activitystreams"},
+			Type:    activityType,
+			ID:      "https://" + h.config.Concurrent.FQDN + "/ap/note/" + ref.CcObjectID + "/activity",
+			Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + entity.ID,
+			To:      []string{"https://www.w3.org/ns/activitystreams#Public"},
+			Cc:      followerActorIRIs(pendingFollowers),
+			Object:  note,
+		}
+
+		inboxes := make([]string, 0, len(pendingFollowers))
+		for _, follower := range pendingFollowers {
+			inboxes = append(inboxes, preferredInbox(follower))
+		}
+
+		if err := h.EnqueueDelivery(ctx, inboxes, activity, entity); err != nil {
+			log.Printf("replay %v: error enqueueing %v: %v", entity.ID, ref.CcObjectID, err)
+			continue
+		}
+
+		for _, i := range pending {
+			if err := h.repo.UpdateFollowerDeliveryCursor(ctx, followers[i].ID, ref.CreatedAt); err != nil {
+				log.Printf("replay %v: error advancing cursor for %v: %v", entity.ID, followers[i].ID, err)
+				continue
+			}
+			followers[i].LastDeliveredAt = ref.CreatedAt
+		}
+	}
+
+	log.Printf("replay %v: caught up %v followers on %v missed posts", entity.ID, len(followers), len(refs))
+}
+
 func (h *Handler) StartAssociationWorker(notificationStream string) {
 
 	ctx := context.Background()
@@ -182,7 +405,10 @@ func (h *Handler) StartAssociationWorker(notificationStream string) {
 
 		ass, err := h.association.Get(ctx, associationID)
 		if err != nil {
-			log.Printf("error: %v", err)
+			// the association no longer exists; if we previously sent an
+			// Announce for it (a CC boost/reroute), retract it
+			h.undoSentAnnounce(ctx, associationID)
+			continue
 		}
 
 		if ass.TargetType != "messages" {
@@ -273,7 +499,7 @@ func (h *Handler) StartAssociationWorker(notificationStream string) {
 				Object:  ref,
 			}
 
-			err = h.PostToInbox(ctx, dest, like, assauthor)
+			err = h.EnqueueDelivery(ctx, []string{dest}, like, assauthor)
 			if err != nil {
 				log.Printf("error: %v", err)
 				continue
@@ -326,7 +552,7 @@ func (h *Handler) StartAssociationWorker(notificationStream string) {
 				},
 			}
 
-			err = h.PostToInbox(ctx, dest, create, assauthor)
+			err = h.EnqueueDelivery(ctx, []string{dest}, create, assauthor)
 			if err != nil {
 				log.Printf("error: %v", err)
 				continue
@@ -366,19 +592,22 @@ func (h *Handler) StartAssociationWorker(notificationStream string) {
 			}
 
 			if content == "" { // boost
-				announce := Object{
-					Context: []string{"https://www.w3.org/ns/activitystreams"},
-					Type:    "Announce",
-					ID:      "https://" + h.config.Concurrent.FQDN + "/ap/note/" + messageId,
-					Actor:   "https://" + h.config.Concurrent.FQDN + "/ap/acct/" + assauthor.ID,
-					Content: "",
-					Object:  ref,
-				}
-				err = h.PostToInbox(ctx, dest, announce, assauthor)
+				err = h.SendAnnounce(ctx, dest, messageId, ref, assauthor)
 				if err != nil {
 					log.Printf("error: %v", err)
 					continue
 				}
+
+				// remember which association produced this Announce, so a
+				// later Undo(reroute) knows which activity to retract
+				announceID := "https://" + h.config.Concurrent.FQDN + "/ap/note/" + messageId
+				if err := h.repo.CreateApObjectReference(ctx, ApObjectReference{
+					ApObjectID: announceID,
+					CcObjectID: ass.ID,
+					EntityID:   assauthor.ID,
+				}); err != nil {
+					log.Printf("error recording announce reference: %v", err)
+				}
 			} else { // quote
 				create := Object{
 					Context: []string{"https://www.w3.org/ns/activitystreams"},
@@ -395,7 +624,7 @@ func (h *Handler) StartAssociationWorker(notificationStream string) {
 					},
 				}
 
-				err = h.PostToInbox(ctx, dest, create, assauthor)
+				err = h.EnqueueDelivery(ctx, []string{dest}, create, assauthor)
 				if err != nil {
 					log.Printf("error: %v", err)
 					continue
@@ -407,6 +636,225 @@ func (h *Handler) StartAssociationWorker(notificationStream string) {
 	}
 }
 
+// deleteOutboundNote retracts a note this bridge previously mirrored out,
+// once the underlying CC message has been deleted. It sends Delete{Tombstone}
+// to the owner's current followers and drops the ApObjectReference.
+func (h *Handler) deleteOutboundNote(ctx context.Context, entity ApEntity, messageID string) {
+	ref, err := h.repo.GetApObjectReferenceByCcObjectID(ctx, messageID)
+	if err != nil || ref.ApObjectID == "" {
+		return // we never mirrored this message out
+	}
+
+	followers, err := h.repo.GetFollowers(ctx, entity.ID)
+	if err != nil {
+		log.Printf("delete outbound note: %v", err)
+		return
+	}
+	inboxes := make([]string, 0, len(followers))
+	for _, follower := range followers {
+		inboxes = append(inboxes, preferredInbox(follower))
+	}
+
+	del := h.DeleteToActivity(ref.ApObjectID, entity.ID)
+	if err := h.EnqueueDelivery(ctx, inboxes, del, entity); err != nil {
+		log.Printf("delete outbound note: %v", err)
+		return
+	}
+
+	if err := h.repo.DeleteApObjectReference(ctx, ref.ApObjectID); err != nil {
+		log.Printf("delete outbound note: %v", err)
+	}
+}
+
+// undoSentAnnounce retracts a previously-sent Announce (boost) once the CC
+// association that produced it has been deleted. It looks up the Announce
+// we recorded for associationID, re-resolves the boosted note's publisher
+// inbox, and delivers an Undo{Announce} to it.
+func (h *Handler) undoSentAnnounce(ctx context.Context, associationID string) {
+	ref, err := h.repo.GetApObjectReferenceByCcObjectID(ctx, associationID)
+	if err != nil || ref.ApObjectID == "" {
+		return // not an announce we sent
+	}
+
+	fqdn := h.config.Concurrent.FQDN
+	messageId := strings.TrimPrefix(ref.ApObjectID, "https://"+fqdn+"/ap/note/")
+
+	msg, err := h.message.Get(ctx, messageId, h.apconfig.ProxyCCID)
+	if err != nil {
+		log.Printf("undo announce: boosted message not found: %v", err)
+		return
+	}
+
+	var msgObject message.SignedObject
+	if err := json.Unmarshal([]byte(msg.Payload), &msgObject); err != nil {
+		log.Printf("undo announce: %v", err)
+		return
+	}
+
+	msgMeta, ok := msgObject.Meta.(map[string]interface{})
+	if !ok {
+		return
+	}
+	dest, ok := msgMeta["apPublisherInbox"].(string)
+	if !ok {
+		return
+	}
+
+	entity, err := h.repo.GetEntityByID(ctx, ref.EntityID)
+	if err != nil {
+		log.Printf("undo announce: signing entity not found: %v", err)
+		return
+	}
+
+	undo := h.UndoAnnounceToActivity(ref.ApObjectID, entity.ID)
+	if err := h.EnqueueDelivery(ctx, []string{dest}, undo, entity); err != nil {
+		log.Printf("undo announce: %v", err)
+		return
+	}
+
+	if err := h.repo.DeleteApObjectReference(ctx, ref.ApObjectID); err != nil {
+		log.Printf("undo announce: %v", err)
+	}
+}
+
+// EnqueueDelivery queues a signed POST of object to each of the given inboxes.
+// Duplicate inboxes (e.g. several followers sharing one sharedInbox) are
+// coalesced into a single delivery job.
+func (h *Handler) EnqueueDelivery(ctx context.Context, inboxes []string, object interface{}, entity ApEntity) error {
+	ctx, span := tracer.Start(ctx, "EnqueueDelivery")
+	defer span.End()
+
+	payload, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, inbox := range inboxes {
+		if inbox == "" || seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+
+		_, err := h.repo.CreateDelivery(ctx, ApDelivery{
+			Inbox:       inbox,
+			Payload:     string(payload),
+			EntityID:    entity.ID,
+			NextAttempt: time.Now(),
+		})
+		if err != nil {
+			log.Printf("error enqueueing delivery to %v: %v", inbox, err)
+			continue
+		}
+		deliveriesQueued.Inc()
+	}
+
+	return nil
+}
+
+// StartDeliveryWorker polls the ap_deliveries table and attempts to deliver
+// due jobs, rescheduling with exponential backoff on transient failure and
+// dead-lettering jobs that exhaust their attempts or hit a permanent 4xx.
+// Jobs run concurrently so one host that's slow or sitting on a long
+// Retry-After can't head-of-line-block the rest of the due batch.
+func (h *Handler) StartDeliveryWorker() {
+	ticker := time.NewTicker(10 * time.Second)
+
+	for range ticker.C {
+		ctx := context.Background()
+
+		jobs, err := h.repo.GetDueDeliveries(ctx, 50)
+		if err != nil {
+			log.Printf("error: %v", err)
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, job := range jobs {
+			job := job
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.processDeliveryJob(ctx, job)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// processDeliveryJob attempts a single queued delivery, dead-lettering it on
+// a permanent failure or rescheduling it with backoff on a transient one.
+func (h *Handler) processDeliveryJob(ctx context.Context, job ApDelivery) {
+	entity, err := h.repo.GetEntityByID(ctx, job.EntityID)
+	if err != nil {
+		log.Printf("delivery %v: signing entity not found: %v", job.ID, err)
+		job.Dead = true
+		job.LastError = "signing entity not found"
+		h.repo.UpdateDelivery(ctx, job)
+		deliveriesFailed.Inc()
+		return
+	}
+
+	err = h.PostToInbox(ctx, job.Inbox, json.RawMessage(job.Payload), entity)
+	if err == nil {
+		h.repo.DeleteDelivery(ctx, job.ID)
+		deliveriesSent.Inc()
+		return
+	}
+
+	statusCode := 0
+	if deliveryErr, ok := err.(*DeliveryError); ok {
+		statusCode = deliveryErr.StatusCode
+	}
+
+	if statusCode == http.StatusGone {
+		if err := h.repo.RemoveFollowersByInbox(ctx, job.Inbox); err != nil {
+			log.Printf("delivery %v: failed to remove followers for gone inbox %v: %v", job.ID, job.Inbox, err)
+		}
+		job.Dead = true
+		job.LastError = "410 Gone: follower removed"
+		h.repo.UpdateDelivery(ctx, job)
+		deliveriesFailed.Inc()
+		return
+	}
+
+	job.Attempt++
+	job.LastError = err.Error()
+
+	if !isRetryableStatus(statusCode) || job.Attempt >= maxDeliveryAttempts {
+		job.Dead = true
+		log.Printf("delivery %v to %v dead-lettered: %v", job.ID, job.Inbox, err)
+		deliveriesFailed.Inc()
+	} else {
+		job.NextAttempt = time.Now().Add(deliveryBackoff(job.Attempt - 1))
+		log.Printf("delivery %v to %v failed, retrying at %v: %v", job.ID, job.Inbox, job.NextAttempt, err)
+		deliveriesRetried.Inc()
+	}
+
+	h.repo.UpdateDelivery(ctx, job)
+}
+
+// preferredInbox returns the inbox a delivery to this follower should target:
+// its sharedInbox when it advertised one (so deliveries to several followers
+// on the same remote server collapse into a single EnqueueDelivery job),
+// falling back to its personal inbox otherwise.
+func preferredInbox(f ApFollower) string {
+	if f.SubscriberSharedInbox != "" {
+		return f.SubscriberSharedInbox
+	}
+	return f.SubscriberInbox
+}
+
+// followerActorIRIs lists the actor IRIs of followers, so an activity that
+// fans out through a shared inbox still names every recipient it covers.
+func followerActorIRIs(followers []ApFollower) []string {
+	iris := make([]string, 0, len(followers))
+	for _, follower := range followers {
+		iris = append(iris, follower.SubscriberPersonURL)
+	}
+	return iris
+}
+
 func isInList(server string, list []string) bool {
 	for _, s := range list {
 		if s == server {